@@ -1,21 +1,81 @@
 package analyze
 
 import (
-	"errors"
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/anthonydip/sherlock/internal/ai"
+	"github.com/anthonydip/sherlock/internal/ci"
 	"github.com/anthonydip/sherlock/internal/cli"
+	"github.com/anthonydip/sherlock/internal/cluster"
+	"github.com/anthonydip/sherlock/internal/config"
+	"github.com/anthonydip/sherlock/internal/forge"
 	"github.com/anthonydip/sherlock/internal/git"
+	"github.com/anthonydip/sherlock/internal/kb"
 	"github.com/anthonydip/sherlock/internal/logger"
 	"github.com/anthonydip/sherlock/internal/parsers"
+	"github.com/anthonydip/sherlock/internal/report"
+	"github.com/anthonydip/sherlock/internal/source"
+	"github.com/anthonydip/sherlock/internal/tools"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 )
 
+// Settings shared by the analysis stages
+type options struct {
+	testOutput string
+	parserName string
+	sourceRoot string
+
+	// Git enrichment
+	noGit        bool
+	noGitCache   bool
+	force        bool
+	uncommitted  bool
+	gitDepth     int
+	contextLines int
+	commitDepth  int
+	frameDepth   int
+	baseRef      string
+
+	// Known issues
+	kbDir string
+	noKB  bool
+
+	// Run history
+	noHistory bool
+
+	// AI analysis and output
+	noTools         bool
+	maxToolSteps    int
+	proposePatch    bool
+	verifyPatch     bool
+	verifyRounds    int
+	batch           bool
+	noCluster       bool
+	format          string
+	outputPath      string
+	usingOutputFlag bool
+
+	// CI annotations
+	ci              string
+	codeQualityFile string
+
+	// Notifications
+	configPath string
+	noNotify   bool
+
+	// Pull request comment
+	postComment bool
+	forgeKind   string
+	forgeURL    string
+	forgeRepo   string
+	pullRequest int
+	headSHA     string
+}
+
 func NewAnalyzeCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "analyze [test-output]",
@@ -31,22 +91,7 @@ func NewAnalyzeCmd() *cobra.Command {
 		},
 	}
 
-	// Parser flags
-	cmd.Flags().StringP("parser", "p", "auto", "test parser to use (jest, pytest, mocha, auto)")
-
-	// Git flags
-	cmd.Flags().Int("git-depth", 5, "maximum parent directory levels to search for .git (default: 5)")
-	cmd.Flags().Int("context-lines", 3, "number of surrounding code lines to include in analysis (default: 3)")
-	cmd.Flags().Int("commit-depth", 3, "number of historical commits to analyze (default: 3)")
-	cmd.Flags().Bool("force", false, "proceed analysis with uncommitted changes")
-	cmd.Flags().Bool("no-git", false, "skip Git integration entirely (repository detection and change analysis)")
-
-	// AI flags
-	cmd.Flags().StringP("api-key", "k", "", "AI API key override (openai|groq)")
-	cmd.Flags().StringP("model", "m", "", "ai model to use (default: gpt-3.5-turbo|llama3-70b-8192)")
-	cmd.Flags().String("ai-provider", "", "ai provider to use (openai|groq)")
-	cmd.Flags().BoolP("batch", "b", false, "batch multiple test failures into one AI request (default: false)")
-	cmd.Flags().StringP("output", "o", "", "write output to file (default: .md)")
+	addFlags(cmd)
 
 	cmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
 		option := cli.StripInvalidFlag(err)
@@ -59,252 +104,510 @@ func NewAnalyzeCmd() *cobra.Command {
 	})
 
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
-		testOutput := args[0]
-		parserName, _ := cmd.Flags().GetString("parser")
-		force, _ := cmd.Flags().GetBool("force")
-		depth, _ := cmd.Flags().GetInt("git-depth")
-		contextLines, _ := cmd.Flags().GetInt("context-lines")
-		commitDepth, _ := cmd.Flags().GetInt("commit-depth")
-		noGit, _ := cmd.Flags().GetBool("no-git")
-		batch, _ := cmd.Flags().GetBool("batch")
-		outputPath, _ := cmd.Flags().GetString("output")
-		usingOutputFlag := cmd.Flags().Changed("output")
-
-		aiOpts, err := getAIOptions(cmd)
+		opts := getOptions(cmd, args[0])
+
+		if err := report.ValidateFormat(opts.format); err != nil {
+			logger.GlobalLogger.Errorf("%s", err)
+			return err
+		}
+
+		provider, err := ci.Resolve(opts.ci)
 		if err != nil {
 			logger.GlobalLogger.Errorf("%s", err)
 			return err
 		}
 
-		logger.GlobalLogger.Debugf("Starting analysis of %s", testOutput)
+		cfg, err := loadConfig(opts)
+		if err != nil {
+			return err
+		}
+		if err := checkVerify(opts, cfg); err != nil {
+			logger.GlobalLogger.Errorf("%s", err)
+			return err
+		}
 
-		logger.GlobalLogger.Debugf("Detected AI options: %s and %s", aiOpts.Provider, aiOpts.Model)
+		// Fail before the analysis when the pull request cannot be found
+		var pullRequest forge.Forge
+		if opts.postComment {
+			if pullRequest, err = newForge(opts); err != nil {
+				logger.GlobalLogger.Errorf("%s", err)
+				return err
+			}
+		}
+
+		// Keep stdout clean for machine-readable output
+		if opts.format != report.FormatMarkdown && !opts.usingOutputFlag {
+			logger.GlobalLogger.SetOutput(os.Stderr)
+		}
 
-		// Parser selection
-		logger.GlobalLogger.Debugf("Selecting '%s' parser", parserName)
-		parser, err := parsers.GetParser(parserName, testOutput)
+		aiOpts, err := cli.GetAIOptions(cmd)
 		if err != nil {
-			logger.GlobalLogger.Errorf("Parser selection failed: %v", err)
-			return fmt.Errorf("test file not found: %s", testOutput)
+			logger.GlobalLogger.Errorf("%s", err)
+			return err
 		}
 
-		// Parse test file
-		failures, err := parser.Parse()
+		logger.GlobalLogger.Debugf("Starting analysis of %s", opts.testOutput)
+
+		logger.GlobalLogger.Debugf("Detected AI options: %s and %s", aiOpts.Provider, aiOpts.Model)
+
+		// Parse the test output
+		failures, results, err := parseFailures(opts)
 		if err != nil {
-			logger.GlobalLogger.Errorf("Parsing failed: %v", err)
-			return fmt.Errorf("parser error: %w", err)
+			return err
 		}
 
+		// Passing runs are part of the history flaky tests are detected from
+		runs := newRunHistory(opts, results, failures)
+		defer runs.save()
+
 		if len(failures) > 0 {
 			logger.GlobalLogger.Successf("Found %d test failures", len(failures))
 		} else {
 			logger.GlobalLogger.Successf("All test cases passed, no failures found")
+			if pullRequest != nil {
+				return postComment(pullRequest, report.New(opts.testOutput))
+			}
 			return nil
 		}
 
-		if noGit {
-			logger.GlobalLogger.Verbosef("--no-git used, skipping Git integration")
+		rep, err := runAnalysis(opts, aiOpts, cfg, failures, runs)
+		if err != nil {
+			return err
+		}
+
+		// Report the results
+		if err := writeReport(opts, rep); err != nil {
+			return err
+		}
+		annotateCI(opts, provider, rep)
+		sendNotifications(opts, cfg, rep)
+
+		if pullRequest != nil {
+			if err := postComment(pullRequest, rep); err != nil {
+				return err
+			}
+		}
+
+		logger.GlobalLogger.Successf("Analysis completed")
+		return nil
+	}
+
+	return cmd
+}
+
+// Registers the flags shared by analyze and diff
+func addFlags(cmd *cobra.Command) {
+	// Parser flags
+	cmd.Flags().StringP("parser", "p", "auto", "test parser to use (jest, pytest, mocha, auto)")
+	cmd.Flags().String("source-root", "", "directory to find source files in (default: repository root or current directory)")
+
+	// Git flags
+	cmd.Flags().Int("git-depth", 5, "maximum parent directory levels to search for .git (default: 5)")
+	cmd.Flags().Int("context-lines", 3, "number of surrounding code lines to include in analysis (default: 3)")
+	cmd.Flags().Int("commit-depth", 3, "number of historical commits to analyze (default: 3)")
+	cmd.Flags().Int("frame-depth", 3, "number of project stack frames to analyze per failure (default: 3)")
+	cmd.Flags().String("base", "", "ref to diff the current branch against (default: merge-base with main/master)")
+	cmd.Flags().Bool("force", false, "proceed analysis with uncommitted changes")
+	cmd.Flags().Bool("uncommitted", false, "include uncommitted working tree changes in the analysis")
+	cmd.Flags().Bool("no-git-cache", false, "disable the on-disk blame and history cache under .git/sherlock")
+	cmd.Flags().Bool("no-git", false, "skip Git integration entirely (repository detection and change analysis)")
+	cmd.Flags().Bool("no-history", false, "do not record test results or check them for flaky tests")
+
+	// AI flags
+	cli.AddAIFlags(cmd)
+	cmd.Flags().Bool("no-tools", false, "do not let the AI read more of the repository through tool calls")
+	cmd.Flags().Int("max-tool-steps", ai.DefaultMaxSteps, "maximum rounds of AI tool calls per analysis (default: 6)")
+	cmd.Flags().Bool("propose-patch", false, "ask the AI for a fix as a patch, checked to apply to the working tree")
+	cmd.Flags().Bool("verify-patch", false, "propose patches and rerun the failing tests with them, using test.command from the configuration")
+	cmd.Flags().Int("verify-rounds", defaultVerifyRounds, "maximum patches tried per failure until the tests pass (default: 3)")
+	cmd.Flags().BoolP("batch", "b", false, "batch multiple test failures into one AI request (default: false)")
+	cmd.Flags().Bool("no-cluster", false, "analyze every failure separately instead of once per group of identical failures")
+	cmd.Flags().String("kb-dir", "", "directory of known issues matched before AI analysis (default: .sherlock/known-issues)")
+	cmd.Flags().Bool("no-kb", false, "skip matching failures against known issues")
+	cmd.Flags().StringP("output", "o", "", "write output to file (default: .md)")
+	cmd.Flags().String("format", report.FormatMarkdown, "output format (markdown, json, html, sarif)")
+
+	// CI flags
+	cmd.Flags().String("ci", ci.Auto, "CI system to annotate failures in (auto, github, gitlab, none)")
+	cmd.Flags().String("codequality-file", ci.DefaultCodeQualityFile, "path of the GitLab code quality report")
+
+	// Notification flags
+	cmd.Flags().String("config", "", "configuration file (default: .sherlock/config.yaml in the repository)")
+	cmd.Flags().Bool("no-notify", false, "do not send the notifications configured in the configuration file")
+
+	// Pull request comment flags
+	cmd.Flags().Bool("post-comment", false, "post the report as a pull request comment, updating the previous one")
+	cmd.Flags().String("forge", "", "platform hosting the pull request (github, gitlab, gitea, default: detected from CI)")
+	cmd.Flags().String("forge-url", "", "REST API URL of the platform (default: detected from CI or the public instance)")
+	cmd.Flags().String("forge-repo", "", "repository to comment on, owner/name or GitLab project path or ID (default: detected from CI)")
+	cmd.Flags().Int("pr", 0, "pull or merge request number to comment on (default: detected from CI)")
+	cmd.Flags().String("head-sha", "", "commit file links point to (default: head of the pull request)")
+}
+
+func getOptions(cmd *cobra.Command, testOutput string) options {
+	opts := options{testOutput: testOutput}
+
+	opts.parserName, _ = cmd.Flags().GetString("parser")
+	opts.sourceRoot, _ = cmd.Flags().GetString("source-root")
+	opts.noGit, _ = cmd.Flags().GetBool("no-git")
+	opts.noGitCache, _ = cmd.Flags().GetBool("no-git-cache")
+	opts.force, _ = cmd.Flags().GetBool("force")
+	opts.uncommitted, _ = cmd.Flags().GetBool("uncommitted")
+	opts.gitDepth, _ = cmd.Flags().GetInt("git-depth")
+	opts.contextLines, _ = cmd.Flags().GetInt("context-lines")
+	opts.commitDepth, _ = cmd.Flags().GetInt("commit-depth")
+	opts.frameDepth, _ = cmd.Flags().GetInt("frame-depth")
+	opts.baseRef, _ = cmd.Flags().GetString("base")
+	opts.noTools, _ = cmd.Flags().GetBool("no-tools")
+	opts.maxToolSteps, _ = cmd.Flags().GetInt("max-tool-steps")
+	opts.proposePatch, _ = cmd.Flags().GetBool("propose-patch")
+	opts.verifyPatch, _ = cmd.Flags().GetBool("verify-patch")
+	opts.verifyRounds, _ = cmd.Flags().GetInt("verify-rounds")
+
+	// Verified patches have to be proposed first
+	if opts.verifyPatch {
+		opts.proposePatch = true
+	}
+	opts.batch, _ = cmd.Flags().GetBool("batch")
+	opts.noCluster, _ = cmd.Flags().GetBool("no-cluster")
+	opts.kbDir, _ = cmd.Flags().GetString("kb-dir")
+	opts.noKB, _ = cmd.Flags().GetBool("no-kb")
+	opts.noHistory, _ = cmd.Flags().GetBool("no-history")
+	opts.format, _ = cmd.Flags().GetString("format")
+	opts.outputPath, _ = cmd.Flags().GetString("output")
+	opts.usingOutputFlag = cmd.Flags().Changed("output")
+	opts.ci, _ = cmd.Flags().GetString("ci")
+	opts.codeQualityFile, _ = cmd.Flags().GetString("codequality-file")
+	opts.configPath, _ = cmd.Flags().GetString("config")
+	opts.noNotify, _ = cmd.Flags().GetBool("no-notify")
+	opts.postComment, _ = cmd.Flags().GetBool("post-comment")
+	opts.forgeKind, _ = cmd.Flags().GetString("forge")
+	opts.forgeURL, _ = cmd.Flags().GetString("forge-url")
+	opts.forgeRepo, _ = cmd.Flags().GetString("forge-repo")
+	opts.pullRequest, _ = cmd.Flags().GetInt("pr")
+	opts.headSHA, _ = cmd.Flags().GetString("head-sha")
+
+	return opts
+}
+
+func parseFailures(opts options) ([]parsers.TestFailure, []parsers.TestResult, error) {
+	// Parser selection
+	logger.GlobalLogger.Debugf("Selecting '%s' parser", opts.parserName)
+	parser, err := parsers.GetParser(opts.parserName, opts.testOutput)
+	if err != nil {
+		logger.GlobalLogger.Errorf("Parser selection failed: %v", err)
+		return nil, nil, fmt.Errorf("test file not found: %s", opts.testOutput)
+	}
+
+	// Parse test file
+	failures, err := parser.Parse()
+	if err != nil {
+		logger.GlobalLogger.Errorf("Parsing failed: %v", err)
+		return nil, nil, fmt.Errorf("parser error: %w", err)
+	}
+
+	return failures, parser.Results(), nil
+}
+
+// Enriches the failures with Git history, code context, flakiness and known
+// issues, then analyzes them
+func runAnalysis(opts options, aiOpts ai.AIOptions, cfg *config.Config, failures []parsers.TestFailure, runs *runHistory) (*report.Report, error) {
+	// Enrich the failures with Git history when available
+	var repo *git.Repository
+	var err error
+	if opts.noGit {
+		logger.GlobalLogger.Verbosef("--no-git used, skipping Git integration")
+	} else {
+		repo, err = enrichFailures(opts, failures)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Code context is read from disk, so it is available without Git
+	root := projectRoot(opts, repo)
+	logger.GlobalLogger.Debugf("Resolving source files under %s", root)
+	resolver := source.NewResolver(root)
+	addCodeContext(resolver, failures, opts.contextLines, opts.frameDepth)
+
+	// Flag tests that failed intermittently in earlier runs
+	runs.markFlaky(failures)
+
+	// Providers with function calling can look beyond the prompt's context
+	if opts.noTools {
+		logger.GlobalLogger.Verbosef("--no-tools used, analyzing from the prompt alone")
+	} else if repo != nil {
+		aiOpts.Tools = tools.New(repo)
+		aiOpts.MaxSteps = opts.maxToolSteps
+	}
+
+	knowledge, err := loadKnowledgeBase(opts, root)
+	if err != nil {
+		return nil, err
+	}
+
+	rep, err := analyzeFailures(opts, aiOpts, failures, knowledge)
+	if err != nil {
+		return nil, err
+	}
+	rep.SourceRoot = root
+
+	// Patches are checked against the working tree, so they need the repository
+	if opts.proposePatch {
+		if repo == nil {
+			logger.GlobalLogger.Warnf("--propose-patch requires a Git repository, skipping patch proposals")
 		} else {
+			proposePatches(aiOpts, repo, resolver, failures, rep, newPatchVerifier(opts, cfg, repo))
+		}
+	}
+
+	return rep, nil
+}
+
+// Returns the directory of the project under test: --source-root, the
+// repository or the working directory
+func projectRoot(opts options, repo *git.Repository) string {
+	root := opts.sourceRoot
+	if root == "" && repo != nil {
+		root = repo.Path()
+	}
+	if root == "" {
+		root, _ = os.Getwd()
+	}
+	return root
+}
 
-			// Attempt to open the Git repository
-			repo, err := git.OpenRepository(filepath.Dir(testOutput), depth)
-			skipGit := err != nil
+func loadKnowledgeBase(opts options, root string) (*kb.KnowledgeBase, error) {
+	if opts.noKB {
+		logger.GlobalLogger.Verbosef("--no-kb used, skipping known issues")
+		return nil, nil
+	}
+
+	dir := opts.kbDir
+	if dir == "" {
+		dir = filepath.Join(root, kb.DefaultDir)
+	}
+
+	knowledge, err := kb.Load(dir)
+	if err != nil {
+		logger.GlobalLogger.Errorf("Failed to load known issues: %v", err)
+		return nil, err
+	}
+
+	return knowledge, nil
+}
+
+// Reads the code around the failure location and the top stack frames
+func addCodeContext(resolver *source.Resolver, failures []parsers.TestFailure, contextLines int, frameDepth int) {
+	for index := range failures {
+		failure := &failures[index]
+
+		if failure.Location != "" && failure.LineNumber > 0 {
+			path, err := resolver.Resolve(source.LocationPath(failure.Location))
 			if err != nil {
-				if errors.Is(err, git.ErrNotAGitRepository) {
-					logger.GlobalLogger.Verbosef("Unable to detect a Git repository within depth of %d (use --depth to change)", depth)
-					logger.GlobalLogger.Warnf("Not running in a Git repository, skipping Git analysis")
-				} else {
-					logger.GlobalLogger.Errorf("Git error: %v", err)
-					return fmt.Errorf("git error: %v", err)
+				logger.GlobalLogger.Warnf("Failure %d - %v", index+1, err)
+			} else {
+				// Suite-level failures have no context yet
+				if failure.Context == nil {
+					failure.Context = &parsers.TestFailureContext{}
 				}
-			}
 
-			// Run Git analysis if running in a Git repository
-			if !skipGit {
-				// Check for uncommitted changes
-				dirty, err := repo.IsDirty()
+				// Get code context around the failing line
+				context, err := source.GetCodeContext(path, failure.LineNumber, contextLines)
 				if err != nil {
-					logger.GlobalLogger.Errorf("Failed to check repo status: %v", err)
-					return fmt.Errorf("git error: %v", err)
+					logger.GlobalLogger.Errorf("Failure %d - Failed to get code context: %v", index+1, err)
+				} else {
+					failure.Context.SurroundingCode = context
+					logger.GlobalLogger.Debugf("Failure %d - Code context:\n%s", index+1, context)
 				}
 
-				// If any uncommitted changes were found
-				if dirty {
-					if force {
-						logger.GlobalLogger.Warnf("Uncommitted changes detected, proceeding with analysis")
-					} else {
-						logger.GlobalLogger.Errorf("Uncommitted changes detected (use --force to override)")
-						return fmt.Errorf("uncommitted changes detected")
-					}
+				// Get full file content
+				// NOTE: Will be expensive if working with large files
+				fullContent, err := source.GetFullFileContent(path)
+				if err != nil {
+					logger.GlobalLogger.Errorf("Failure %d - Failed to get full file: %v", index+1, err)
+				} else {
+					failure.Context.FullFileContent = fullContent
 				}
+			}
+		}
 
-				// Get commit history for the affected files
-				for index := range failures {
-					failure := &failures[index]
-
-					// Convert absolute path to repo-relative path
-					relPath, err := git.NormalizeTestPath(failure.Location, repo.Path())
-					if err != nil {
-						logger.GlobalLogger.Errorf("Failure %d - Failed to normalize path: %v", index+1, err)
-						continue
-					}
-
-					logger.GlobalLogger.Debugf("Failure %d - Analyzing failure in: %s", index+1, relPath)
-
-					// Get Git commit history for the affected file
-					commitHistory, err := repo.GetEnhancedFileHistory(relPath, commitDepth)
-					if err != nil {
-						logger.GlobalLogger.Errorf("Failure %d - Failed to get commit history: %v", index+1, err)
-						return err
-					}
-
-					// Log the commit information
-					for _, commit := range commitHistory {
-						logger.GlobalLogger.Verbosef("Failure %d - Related commit for %s: %s by %s at %s",
-							index+1,
-							failure.TestName,
-							commit.Hash[:7],
-							commit.Author,
-							commit.Date.Format("2006-01-02"),
-						)
-						logger.GlobalLogger.Debugf("Failure %d - Commit message: %s", index+1, commit.Message)
-						logger.GlobalLogger.Debugf("Failure %d - Files changed: %v", index+1, commit.Changes)
-					}
-
-					// Get line-specific changes if we have a line number
-					if failure.LineNumber > 0 {
-						// Get the exact line changes
-						lineChanges, err := repo.GetLineChanges(relPath, failure.LineNumber)
-						if err != nil {
-							logger.GlobalLogger.Errorf("Failure %d - Failed to get line changes: %v", index+1, err)
-						} else {
-							logger.GlobalLogger.Debugf("Failure %d - Line changes:\n%s", index+1, lineChanges)
-							failure.CodeChanges = lineChanges
-						}
-
-						// Get code context around the line-specific change
-						absPath := filepath.Join(repo.Path(), relPath)
-						context, err := repo.GetCodeContext(absPath, failure.LineNumber, contextLines)
-						if err != nil {
-							logger.GlobalLogger.Errorf("Failure %d - Failed to get code context: %v", index+1, err)
-						} else {
-							failure.Context.SurroundingCode = context
-							logger.GlobalLogger.Debugf("Failure %d - Code context:\n%s", index+1, context)
-						}
-
-						// Get full file content
-						// NOTE: Will be expensive if working with large files
-						fullContent, err := repo.GetFullFileContent(absPath)
-						if err != nil {
-							logger.GlobalLogger.Errorf("Failure %d - Failed to get full file: %v", index+1, err)
-						} else {
-							failure.Context.FullFileContent = fullContent
-						}
-
-						// Get commits that modified this line
-						lineCommits, err := repo.GetCommitsAffectingLines(relPath, []int{failure.LineNumber}, commitDepth)
-						if err != nil {
-							logger.GlobalLogger.Debugf("Failure %d - Failed to get line-specific commits: %v", index+1, err)
-						} else {
-							failure.RelatedCommits = lineCommits
-							for _, commit := range lineCommits {
-								logger.GlobalLogger.Verbosef("Failure %d - Line %d modified in commit %s: %s",
-									index+1,
-									failure.LineNumber,
-									commit.Hash[:7],
-									strings.Split(commit.Message, "\n")[0],
-								)
-							}
-						}
-
-					}
-				}
+		for i := range failure.StackFrames {
+			if i >= frameDepth {
+				break
+			}
+			frame := &failure.StackFrames[i]
 
-				logger.GlobalLogger.Verbosef("Generating prompts for AI analysis")
-
-				if batch {
-					// Batch multiple test failures into one request
-					prompt := ai.GenerateBatchPrompt(failures)
-					logger.GlobalLogger.Debugf("Generated prompt for failure(s):\n%s", prompt)
-
-					aiClient, err := ai.NewAIClient(aiOpts)
-					if err != nil {
-						logger.GlobalLogger.Errorf("Failed to create AI client: %v", err)
-						return err
-					}
-					logger.GlobalLogger.Verbosef("Initialized AI Client for %s with %s", aiOpts.Provider, aiOpts.Model)
-
-					aiResponse, err := aiClient.AnalyzeTestFailure(prompt)
-					if err != nil {
-						logger.GlobalLogger.Errorf("AI request failed: %v", err)
-						return err
-					}
-
-					if !usingOutputFlag {
-						// Output AI response to terminal
-						logger.GlobalLogger.Successf("%v\n", aiResponse)
-					} else {
-						// Write AI response to file
-						if filepath.Ext(outputPath) != ".md" {
-							originalPath := outputPath
-							outputPath += ".md"
-							logger.GlobalLogger.Warnf("Output file should use .md extension. Changed '%s' → '%s'", originalPath, outputPath)
-						}
-
-						if err := os.WriteFile(outputPath, []byte(aiResponse), 0644); err != nil {
-							logger.GlobalLogger.Errorf("Failed to write AI response to file: %v", err)
-							return err
-						}
-						logger.GlobalLogger.Verbosef("AI analysis saved to %s", outputPath)
-					}
+			path, err := resolver.Resolve(frame.File)
+			if err != nil {
+				logger.GlobalLogger.Debugf("Failure %d - Frame %d: %v", index+1, i+1, err)
+				continue
+			}
 
-				} else {
-					aiClient, err := ai.NewAIClient(aiOpts)
-					if err != nil {
-						logger.GlobalLogger.Errorf("Failed to create AI client: %v", err)
-						return err
-					}
-					logger.GlobalLogger.Verbosef("Initialized AI Client for %s with %s", aiOpts.Provider, aiOpts.Model)
-
-					// Generate prompt for each test failure
-					for i, failure := range failures {
-						prompt := ai.GeneratePrompt(failure)
-
-						logger.GlobalLogger.Debugf("Generated prompt for failure %d:\n%s", i+1, prompt)
-
-						aiResponse, err := aiClient.AnalyzeTestFailure(prompt)
-						if err != nil {
-							logger.GlobalLogger.Errorf("AI request failed: %v", err)
-							return err
-						}
-
-						if !usingOutputFlag {
-							// Output AI response to terminal
-							logger.GlobalLogger.Successf("%v\n", aiResponse)
-						} else {
-							// Write AI response to file
-							if filepath.Ext(outputPath) != ".md" {
-								originalPath := outputPath
-								outputPath += ".md"
-								logger.GlobalLogger.Warnf("Output file should use .md extension. Changed '%s' → '%s'", originalPath, outputPath)
-							}
-
-							if err := os.WriteFile(outputPath, []byte(aiResponse), 0644); err != nil {
-								logger.GlobalLogger.Errorf("Failed to write AI response to file: %v", err)
-								return err
-							}
-							logger.GlobalLogger.Verbosef("AI analysis saved to %s", outputPath)
-						}
-					}
-				}
+			context, err := source.GetCodeContext(path, frame.LineNumber, contextLines)
+			if err != nil {
+				logger.GlobalLogger.Debugf("Failure %d - Failed to get code context for frame %d: %v", index+1, i+1, err)
+				continue
 			}
+			frame.SurroundingCode = context
 		}
+	}
+}
 
-		logger.GlobalLogger.Successf("Analysis completed")
+func analyzeFailures(opts options, aiOpts ai.AIOptions, failures []parsers.TestFailure, knowledge *kb.KnowledgeBase) (*report.Report, error) {
+	rep := report.New(opts.testOutput)
+
+	// Failures with the same signature share one analysis
+	var clusters []cluster.Cluster
+	if opts.noCluster {
+		clusters = cluster.Single(failures)
+	} else {
+		clusters = cluster.Group(failures)
+		if len(clusters) < len(failures) {
+			logger.GlobalLogger.Successf("Grouped %d failures into %d clusters", len(failures), len(clusters))
+		}
+	}
+
+	// Known issues matching the error itself are answered without the AI
+	known := make([]*kb.Match, len(clusters))
+	var remaining []parsers.TestFailure
+	for c, group := range clusters {
+		number := group.Indexes[0] + 1
+		known[c] = knowledge.Match(failures[group.Indexes[0]])
+		if known[c] != nil && known[c].Confident {
+			logger.GlobalLogger.Verbosef("Failure %d - Matched known issue %s, skipping AI analysis", number, known[c].Entry.ID)
+			continue
+		}
+		if known[c] != nil {
+			logger.GlobalLogger.Verbosef("Failure %d - Possibly matches known issue %s", number, known[c].Entry.ID)
+		}
+		remaining = append(remaining, failures[group.Indexes[0]])
+	}
+
+	var aiClient ai.AIClient
+	if len(remaining) > 0 {
+		logger.GlobalLogger.Verbosef("Generating prompts for AI analysis")
+
+		var err error
+		aiClient, err = ai.NewAIClient(aiOpts)
+		if err != nil {
+			logger.GlobalLogger.Errorf("Failed to create AI client: %v", err)
+			return nil, err
+		}
+		logger.GlobalLogger.Verbosef("Initialized AI Client for %s with %s", aiOpts.Provider, aiOpts.Model)
+	}
+
+	if opts.batch && len(remaining) > 0 {
+		// Batch one failure per cluster into one request
+		aiResponse, err := ai.AnalyzeFailures(aiClient, remaining)
+		if err != nil {
+			logger.GlobalLogger.Errorf("AI request failed: %v", err)
+			return nil, err
+		}
+
+		rep.Analysis = aiResponse
+	}
+
+	// Analyze the first failure of each cluster
+	analyses := make([]string, len(failures))
+	knownIssues := make([]string, len(failures))
+	for c, group := range clusters {
+		index := group.Indexes[0]
+		match := known[c]
+
+		var analysis, knownIssue string
+		switch {
+		case match != nil && match.Confident:
+			analysis = match.Entry.Analysis()
+			knownIssue = match.Entry.ID
+		case opts.batch:
+			// Covered by the combined analysis
+		default:
+			aiResponse, err := ai.AnalyzeFailure(aiClient, failures[index])
+			if err != nil {
+				logger.GlobalLogger.Errorf("AI request failed: %v", err)
+				return nil, err
+			}
+
+			if match != nil {
+				aiResponse += fmt.Sprintf("\n\n_Possibly related known issue %s: %s_", match.Entry.ID, match.Entry.Title)
+			}
+			analysis = aiResponse
+		}
+
+		for _, i := range group.Indexes {
+			analyses[i] = analysis
+			knownIssues[i] = knownIssue
+		}
+	}
+
+	for i, failure := range failures {
+		entry := rep.AddFailure(failure, analyses[i])
+		entry.KnownIssue = knownIssues[i]
+	}
+
+	if !opts.noCluster {
+		for _, group := range clusters {
+			rep.AddCluster(group.Signature, group.Indexes)
+		}
+	}
+
+	return rep, nil
+}
+
+// Prints the report, or writes it to the output file when one was given
+func writeReport(opts options, rep *report.Report) error {
+	var content string
+	switch opts.format {
+	case report.FormatJSON:
+		var buffer bytes.Buffer
+		if err := rep.WriteJSON(&buffer); err != nil {
+			logger.GlobalLogger.Errorf("Failed to encode report: %v", err)
+			return err
+		}
+		content = buffer.String()
+	case report.FormatHTML:
+		var buffer bytes.Buffer
+		if err := rep.WriteHTML(&buffer); err != nil {
+			logger.GlobalLogger.Errorf("Failed to render report: %v", err)
+			return err
+		}
+		content = buffer.String()
+	case report.FormatSARIF:
+		var buffer bytes.Buffer
+		if err := rep.WriteSARIF(&buffer); err != nil {
+			logger.GlobalLogger.Errorf("Failed to encode report: %v", err)
+			return err
+		}
+		content = buffer.String()
+	default:
+		content = rep.Markdown()
+	}
+
+	if !opts.usingOutputFlag {
+		if opts.format == report.FormatMarkdown {
+			// Output AI response to terminal
+			logger.GlobalLogger.Successf("%v\n", content)
+		} else {
+			fmt.Print(content)
+		}
 		return nil
 	}
 
-	return cmd
+	outputPath := opts.outputPath
+	if extension := report.Extension(opts.format); filepath.Ext(outputPath) != extension {
+		originalPath := outputPath
+		outputPath += extension
+		logger.GlobalLogger.Warnf("Output file should use %s extension. Changed '%s' → '%s'", extension, originalPath, outputPath)
+	}
+
+	if err := os.WriteFile(outputPath, []byte(content), 0644); err != nil {
+		logger.GlobalLogger.Errorf("Failed to write AI response to file: %v", err)
+		return err
+	}
+	logger.GlobalLogger.Verbosef("AI analysis saved to %s", outputPath)
+
+	return nil
 }
 
 func generateOptionGroups(cmd *cobra.Command) []cli.FlagGroup {
@@ -313,6 +616,7 @@ func generateOptionGroups(cmd *cobra.Command) []cli.FlagGroup {
 			Name: "Parser options",
 			Flags: []*pflag.Flag{
 				cmd.Flags().Lookup("parser"),
+				cmd.Flags().Lookup("source-root"),
 			},
 		},
 		{
@@ -321,8 +625,13 @@ func generateOptionGroups(cmd *cobra.Command) []cli.FlagGroup {
 				cmd.Flags().Lookup("git-depth"),
 				cmd.Flags().Lookup("context-lines"),
 				cmd.Flags().Lookup("commit-depth"),
+				cmd.Flags().Lookup("frame-depth"),
+				cmd.Flags().Lookup("base"),
 				cmd.Flags().Lookup("force"),
+				cmd.Flags().Lookup("uncommitted"),
+				cmd.Flags().Lookup("no-git-cache"),
 				cmd.Flags().Lookup("no-git"),
+				cmd.Flags().Lookup("no-history"),
 			},
 		},
 		{
@@ -331,89 +640,37 @@ func generateOptionGroups(cmd *cobra.Command) []cli.FlagGroup {
 				cmd.Flags().Lookup("api-key"),
 				cmd.Flags().Lookup("model"),
 				cmd.Flags().Lookup("ai-provider"),
+				cmd.Flags().Lookup("no-cache"),
+				cmd.Flags().Lookup("cache-ttl"),
+				cmd.Flags().Lookup("no-tools"),
+				cmd.Flags().Lookup("max-tool-steps"),
+				cmd.Flags().Lookup("propose-patch"),
+				cmd.Flags().Lookup("verify-patch"),
+				cmd.Flags().Lookup("verify-rounds"),
 				cmd.Flags().Lookup("batch"),
+				cmd.Flags().Lookup("no-cluster"),
+				cmd.Flags().Lookup("kb-dir"),
+				cmd.Flags().Lookup("no-kb"),
 				cmd.Flags().Lookup("output"),
+				cmd.Flags().Lookup("format"),
+			},
+		},
+		{
+			Name: "CI options",
+			Flags: []*pflag.Flag{
+				cmd.Flags().Lookup("ci"),
+				cmd.Flags().Lookup("codequality-file"),
+				cmd.Flags().Lookup("config"),
+				cmd.Flags().Lookup("no-notify"),
+				cmd.Flags().Lookup("post-comment"),
+				cmd.Flags().Lookup("forge"),
+				cmd.Flags().Lookup("forge-url"),
+				cmd.Flags().Lookup("forge-repo"),
+				cmd.Flags().Lookup("pr"),
+				cmd.Flags().Lookup("head-sha"),
 			},
 		},
 	}
 
 	return groups
 }
-
-func getAIOptions(cmd *cobra.Command) (ai.AIOptions, error) {
-	opts := ai.AIOptions{
-		Provider: cmd.Flag("ai-provider").Value.String(),
-		Model:    cmd.Flag("model").Value.String(),
-	}
-
-	// Get API key (flag takes precedence over env vars)
-	apiKey, err := getAPIKey(cmd, opts.Provider)
-	if err != nil {
-		return ai.AIOptions{}, err
-	}
-	opts.APIKey = apiKey
-
-	// Auto-detect provider if not specified
-	if opts.Provider == "" {
-		opts.Provider, err = detectProviderFromKey(opts.APIKey)
-		if err != nil {
-			return ai.AIOptions{}, err
-		}
-	} else {
-		// Check for invalid provider provided
-		if opts.Provider != "groq" && opts.Provider != "openai" {
-			return ai.AIOptions{}, fmt.Errorf("Invalid ai provider: %s", opts.Provider)
-		}
-	}
-
-	// Set default model if not specified
-	if opts.Model == "" {
-		opts.Model = getDefaultModel(opts.Provider)
-	}
-
-	return opts, nil
-}
-
-func getAPIKey(cmd *cobra.Command, provider string) (string, error) {
-	if key := cmd.Flag("api-key").Value.String(); key != "" {
-		return key, nil
-	}
-
-	switch provider {
-	case "groq":
-		return os.Getenv("GROQ_API_KEY"), nil
-	case "openai":
-		return os.Getenv("OPENAI_API_KEY"), nil
-	default:
-		if key := os.Getenv("GROQ_API_KEY"); key != "" {
-			return key, nil
-		}
-		if key := os.Getenv("OPENAI_API_KEY"); key != "" {
-			return key, nil
-		}
-	}
-
-	return "", fmt.Errorf("No API key provided (use --api-key or set %s_API_KEY)", strings.ToUpper(provider))
-}
-
-func detectProviderFromKey(key string) (string, error) {
-	switch {
-	case strings.HasPrefix(key, "gsk_"):
-		return "groq", nil
-	case strings.HasPrefix(key, "sk-"):
-		return "openai", nil
-	default:
-		return "", fmt.Errorf("Unable to detect provider from key format")
-	}
-}
-
-func getDefaultModel(provider string) string {
-	switch provider {
-	case "groq":
-		return "llama3-70b-8192"
-	case "openai":
-		return "gpt-3.5-turbo"
-	default:
-		return "llama3-70b-8192" // Fallback to Groq free model
-	}
-}
@@ -0,0 +1,45 @@
+package analyze
+
+import (
+	"io"
+	"os"
+
+	"github.com/anthonydip/sherlock/internal/ci"
+	"github.com/anthonydip/sherlock/internal/logger"
+	"github.com/anthonydip/sherlock/internal/report"
+)
+
+// Annotates the failures in the CI system the run is part of. CI output is
+// supplementary, so problems writing it never fail the analysis.
+func annotateCI(opts options, provider string, rep *report.Report) {
+	if provider == ci.None || len(rep.Failures) == 0 {
+		return
+	}
+
+	annotations := ci.Annotations(rep)
+
+	switch provider {
+	case ci.GitHub:
+		// Workflow commands are read from both streams, so keep them out of
+		// machine-readable output on stdout
+		var w io.Writer = os.Stdout
+		if opts.format != report.FormatMarkdown && !opts.usingOutputFlag {
+			w = os.Stderr
+		}
+		ci.WriteGitHubAnnotations(w, annotations)
+		logger.GlobalLogger.Debugf("Wrote %d GitHub annotations", len(annotations))
+
+		written, err := ci.WriteStepSummary(rep.Markdown())
+		if err != nil {
+			logger.GlobalLogger.Warnf("Failed to write job summary: %v", err)
+		} else if written {
+			logger.GlobalLogger.Verbosef("Job summary written to $GITHUB_STEP_SUMMARY")
+		}
+	case ci.GitLab:
+		if err := ci.WriteCodeQuality(opts.codeQualityFile, annotations); err != nil {
+			logger.GlobalLogger.Warnf("Failed to write code quality report: %v", err)
+			return
+		}
+		logger.GlobalLogger.Verbosef("Code quality report saved to %s", opts.codeQualityFile)
+	}
+}
@@ -0,0 +1,76 @@
+package analyze
+
+import (
+	"fmt"
+
+	"github.com/anthonydip/sherlock/internal/forge"
+	"github.com/anthonydip/sherlock/internal/logger"
+	"github.com/anthonydip/sherlock/internal/report"
+)
+
+// Resolves the pull request to comment on, with flags taking precedence over
+// what the CI environment provides
+func newForge(opts options) (forge.Forge, error) {
+	detected := forge.Detect()
+
+	kind := opts.forgeKind
+	if kind == "" {
+		kind = detected.Kind
+	}
+	if kind == "" {
+		return nil, fmt.Errorf("no pull request detected in the environment (use --forge, --forge-repo and --pr)")
+	}
+
+	// Only the detected platform's settings apply to it
+	forgeOpts := forge.Options{Kind: kind}
+	if detected.Kind == kind {
+		forgeOpts = detected
+	}
+
+	if opts.forgeURL != "" {
+		forgeOpts.BaseURL = opts.forgeURL
+		forgeOpts.WebURL = ""
+	}
+	if opts.forgeRepo != "" {
+		forgeOpts.Repo = opts.forgeRepo
+	}
+	if opts.pullRequest > 0 {
+		forgeOpts.Number = opts.pullRequest
+	}
+	if opts.headSHA != "" {
+		forgeOpts.HeadSHA = opts.headSHA
+	}
+	forgeOpts.Token = forge.Token(kind)
+
+	logger.GlobalLogger.Debugf("Commenting on %s pull request %s#%d", kind, forgeOpts.Repo, forgeOpts.Number)
+	return forge.New(forgeOpts)
+}
+
+// Posts the report as a pull request comment, replacing the one left by a
+// previous run. A passing run only updates an existing comment.
+func postComment(f forge.Forge, rep *report.Report) error {
+	passed := len(rep.Failures) == 0 && rep.Comparison == nil
+
+	var body string
+	if passed {
+		body = forge.PassingBody(rep)
+	} else {
+		// Links fall back to plain paths without the head commit
+		commit, err := f.HeadCommit()
+		if err != nil {
+			logger.GlobalLogger.Warnf("Failed to look up the pull request head commit: %v", err)
+		}
+		body = forge.CommentBody(rep, f, commit)
+	}
+
+	written, err := forge.PostComment(f, body, !passed)
+	if err != nil {
+		logger.GlobalLogger.Errorf("Failed to post pull request comment: %v", err)
+		return err
+	}
+	if written {
+		logger.GlobalLogger.Successf("Pull request comment posted")
+	}
+
+	return nil
+}
@@ -0,0 +1,256 @@
+package analyze
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anthonydip/sherlock/internal/ci"
+	"github.com/anthonydip/sherlock/internal/cli"
+	"github.com/anthonydip/sherlock/internal/cluster"
+	"github.com/anthonydip/sherlock/internal/forge"
+	"github.com/anthonydip/sherlock/internal/logger"
+	"github.com/anthonydip/sherlock/internal/parsers"
+	"github.com/anthonydip/sherlock/internal/report"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func NewDiffCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff [baseline] [current]",
+		Short: "Compare two test runs and diagnose the new failures",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				fmt.Fprintf(os.Stderr, "error: a baseline and a current test output are required\n")
+				fmt.Fprint(os.Stderr, cli.FormatUsage(cmd, "<baseline> <current>", generateDiffOptionGroups(cmd)))
+				return fmt.Errorf("Requires exactly 2 test files")
+			}
+			return nil
+		},
+	}
+
+	addFlags(cmd)
+	cmd.Flags().Bool("all", false, "also analyze failures that are still failing from the baseline")
+
+	cmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		option := cli.StripInvalidFlag(err)
+
+		fmt.Fprintf(os.Stderr, "unknown option: %s\n", option)
+		fmt.Fprint(os.Stderr, cli.FormatUsage(cmd, "<baseline> <current>", generateDiffOptionGroups(cmd)))
+		return nil
+	})
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		opts := getOptions(cmd, args[1])
+		all, _ := cmd.Flags().GetBool("all")
+
+		if err := report.ValidateFormat(opts.format); err != nil {
+			logger.GlobalLogger.Errorf("%s", err)
+			return err
+		}
+
+		provider, err := ci.Resolve(opts.ci)
+		if err != nil {
+			logger.GlobalLogger.Errorf("%s", err)
+			return err
+		}
+
+		cfg, err := loadConfig(opts)
+		if err != nil {
+			return err
+		}
+		if err := checkVerify(opts, cfg); err != nil {
+			logger.GlobalLogger.Errorf("%s", err)
+			return err
+		}
+
+		// Fail before the analysis when the pull request cannot be found
+		var pullRequest forge.Forge
+		if opts.postComment {
+			if pullRequest, err = newForge(opts); err != nil {
+				logger.GlobalLogger.Errorf("%s", err)
+				return err
+			}
+		}
+
+		// Keep stdout clean for machine-readable output
+		if opts.format != report.FormatMarkdown && !opts.usingOutputFlag {
+			logger.GlobalLogger.SetOutput(os.Stderr)
+		}
+
+		aiOpts, err := cli.GetAIOptions(cmd)
+		if err != nil {
+			logger.GlobalLogger.Errorf("%s", err)
+			return err
+		}
+
+		// Parse both runs with the same parser settings
+		baselineOpts := opts
+		baselineOpts.testOutput = args[0]
+		baseline, _, err := parseFailures(baselineOpts)
+		if err != nil {
+			return err
+		}
+
+		failures, results, err := parseFailures(opts)
+		if err != nil {
+			return err
+		}
+
+		comparison, statuses := compareRuns(args[0], baseline, failures, results)
+		logger.GlobalLogger.Successf("Compared with baseline: %d new, %d changed, %d still failing, %d fixed",
+			len(comparison.New), len(comparison.Changed), len(comparison.StillFailing), len(comparison.Fixed))
+
+		// Failures already on the baseline are not caused by the current changes
+		var selected []parsers.TestFailure
+		var selectedStatuses []string
+		for i, failure := range failures {
+			if all || statuses[i] == report.StatusNew || statuses[i] == report.StatusChanged {
+				selected = append(selected, failure)
+				selectedStatuses = append(selectedStatuses, statuses[i])
+			}
+		}
+
+		rep := report.New(opts.testOutput)
+		if len(selected) > 0 {
+			// The current run is only compared here, so it is not recorded
+			runs := newRunHistory(opts, results, failures)
+
+			rep, err = runAnalysis(opts, aiOpts, cfg, selected, runs)
+			if err != nil {
+				return err
+			}
+			for i := range rep.Failures {
+				rep.Failures[i].Status = selectedStatuses[i]
+			}
+		} else {
+			logger.GlobalLogger.Successf("No new or changed failures to analyze")
+		}
+		rep.Comparison = comparison
+
+		if err := writeReport(opts, rep); err != nil {
+			return err
+		}
+		annotateCI(opts, provider, rep)
+		sendNotifications(opts, cfg, rep)
+
+		if pullRequest != nil {
+			if err := postComment(pullRequest, rep); err != nil {
+				return err
+			}
+		}
+
+		logger.GlobalLogger.Successf("Comparison completed")
+		return nil
+	}
+
+	return cmd
+}
+
+// Classifies the current failures as new, changed or still failing, and the
+// baseline failures whose tests now pass as fixed. Returns the status of each
+// current failure.
+func compareRuns(baselinePath string, baseline, current []parsers.TestFailure, results []parsers.TestResult) (*report.Comparison, []string) {
+	// Empty categories are encoded as empty lists rather than null
+	comparison := &report.Comparison{
+		Baseline:     baselinePath,
+		New:          []report.ComparedTest{},
+		Changed:      []report.ComparedTest{},
+		StillFailing: []report.ComparedTest{},
+		Fixed:        []report.ComparedTest{},
+	}
+	statuses := make([]string, len(current))
+	matched := make([]bool, len(baseline))
+
+	for i, failure := range current {
+		test := report.ComparedTest{TestName: failure.TestName, File: failure.File, Error: failure.Error}
+
+		b := findTest(failure.File, failure.TestName, len(baseline), func(j int) (string, string) {
+			return baseline[j].File, baseline[j].TestName
+		})
+
+		switch {
+		case b < 0:
+			statuses[i] = report.StatusNew
+			comparison.New = append(comparison.New, test)
+		case cluster.ErrorSignature(baseline[b]) != cluster.ErrorSignature(failure):
+			statuses[i] = report.StatusChanged
+			test.BaselineError = baseline[b].Error
+			comparison.Changed = append(comparison.Changed, test)
+		default:
+			statuses[i] = report.StatusStillFailing
+			comparison.StillFailing = append(comparison.StillFailing, test)
+		}
+
+		if b >= 0 {
+			matched[b] = true
+		}
+	}
+
+	for j, failure := range baseline {
+		if matched[j] {
+			continue
+		}
+
+		// Tests missing from the current run were not fixed, only not run
+		r := findTest(failure.File, failure.TestName, len(results), func(k int) (string, string) {
+			return results[k].File, results[k].TestName
+		})
+		if r < 0 || !results[r].Passed {
+			logger.GlobalLogger.Verbosef("Baseline failure '%s' is not in the current run", failure.TestName)
+			continue
+		}
+
+		comparison.Fixed = append(comparison.Fixed, report.ComparedTest{
+			TestName:      failure.TestName,
+			File:          failure.File,
+			BaselineError: failure.Error,
+		})
+	}
+
+	return comparison, statuses
+}
+
+// Finds the test with the same name whose file shares the longest path
+// suffix with the given file, as runs may come from different checkouts.
+// Returns -1 when no test matches.
+func findTest(file, name string, count int, test func(int) (string, string)) int {
+	best, bestShared := -1, 0
+	for i := 0; i < count; i++ {
+		candidateFile, candidateName := test(i)
+		if candidateName != name {
+			continue
+		}
+
+		if shared := sharedSuffix(file, candidateFile); shared > bestShared {
+			best, bestShared = i, shared
+		}
+	}
+	return best
+}
+
+// Counts the trailing path components two paths have in common
+func sharedSuffix(a, b string) int {
+	partsA := strings.Split(filepath.ToSlash(a), "/")
+	partsB := strings.Split(filepath.ToSlash(b), "/")
+
+	shared := 0
+	for shared < len(partsA) && shared < len(partsB) &&
+		partsA[len(partsA)-1-shared] == partsB[len(partsB)-1-shared] {
+		shared++
+	}
+	return shared
+}
+
+func generateDiffOptionGroups(cmd *cobra.Command) []cli.FlagGroup {
+	groups := append(generateOptionGroups(cmd), cli.FlagGroup{
+		Name: "Comparison options",
+		Flags: []*pflag.Flag{
+			cmd.Flags().Lookup("all"),
+		},
+	})
+
+	return groups
+}
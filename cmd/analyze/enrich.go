@@ -0,0 +1,338 @@
+package analyze
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/anthonydip/sherlock/internal/git"
+	"github.com/anthonydip/sherlock/internal/logger"
+	"github.com/anthonydip/sherlock/internal/owners"
+	"github.com/anthonydip/sherlock/internal/parsers"
+)
+
+// Adds line changes, related commits, suspect changes and contacts from Git
+// to the failures. Returns nil when the test output is not in a repository.
+func enrichFailures(opts options, failures []parsers.TestFailure) (*git.Repository, error) {
+	// Attempt to open the Git repository
+	repo, err := git.OpenRepository(filepath.Dir(opts.testOutput), opts.gitDepth)
+	if err != nil {
+		if errors.Is(err, git.ErrNotAGitRepository) {
+			logger.GlobalLogger.Verbosef("Unable to detect a Git repository within depth of %d (use --git-depth to change)", opts.gitDepth)
+			logger.GlobalLogger.Warnf("Not running in a Git repository, skipping Git analysis")
+			return nil, nil
+		}
+		logger.GlobalLogger.Errorf("Git error: %v", err)
+		return nil, fmt.Errorf("git error: %v", err)
+	}
+
+	if opts.noGitCache {
+		logger.GlobalLogger.Verbosef("--no-git-cache used, disabling on-disk blame cache")
+		repo.UseDiskCache(false)
+	}
+
+	codeOwners, err := owners.Load(repo.Path())
+	if err != nil {
+		logger.GlobalLogger.Warnf("Failed to load CODEOWNERS: %v", err)
+	}
+
+	// Check for uncommitted changes
+	dirty, err := repo.IsDirty()
+	if err != nil {
+		logger.GlobalLogger.Errorf("Failed to check repo status: %v", err)
+		return nil, fmt.Errorf("git error: %v", err)
+	}
+
+	// If any uncommitted changes were found
+	var worktreeDiff []git.FileDiff
+	if dirty {
+		if opts.uncommitted {
+			logger.GlobalLogger.Verbosef("Uncommitted changes detected, including them in analysis")
+			repo.IncludeWorktree(true)
+
+			worktreeDiff, err = repo.GetWorktreeDiff()
+			if err != nil {
+				logger.GlobalLogger.Errorf("Failed to compute uncommitted changes: %v", err)
+				return nil, fmt.Errorf("git error: %v", err)
+			}
+		} else if opts.force {
+			logger.GlobalLogger.Warnf("Uncommitted changes detected, proceeding with analysis")
+		} else {
+			logger.GlobalLogger.Errorf("Uncommitted changes detected (use --uncommitted to include them or --force to override)")
+			return nil, fmt.Errorf("uncommitted changes detected")
+		}
+	}
+
+	// Compute the changes on the current branch since the base ref
+	var branchDiff []git.FileDiff
+	base, err := repo.ResolveBase(opts.baseRef)
+	if err != nil {
+		if opts.baseRef != "" {
+			logger.GlobalLogger.Errorf("Failed to resolve base ref: %v", err)
+			return nil, fmt.Errorf("git error: %v", err)
+		}
+		logger.GlobalLogger.Verbosef("Skipping branch diff: %v", err)
+	} else {
+		branchDiff, err = repo.GetBranchDiff(base)
+		if err != nil {
+			logger.GlobalLogger.Errorf("Failed to compute branch diff: %v", err)
+			return nil, fmt.Errorf("git error: %v", err)
+		}
+		logger.GlobalLogger.Verbosef("Found %d file(s) changed since %s", len(branchDiff), base.Hash.String()[:7])
+	}
+
+	// Get commit history for the affected files
+	for index := range failures {
+		failure := &failures[index]
+
+		// Convert absolute path to repo-relative path
+		relPath, err := git.NormalizeTestPath(failure.Location, repo.Path())
+		if err != nil {
+			logger.GlobalLogger.Errorf("Failure %d - Failed to normalize path: %v", index+1, err)
+			continue
+		}
+
+		logger.GlobalLogger.Debugf("Failure %d - Analyzing failure in: %s", index+1, relPath)
+
+		// Files inside submodules are analyzed against the submodule's repository
+		fileRepo, fileRelPath, err := repo.RepositoryFor(relPath)
+		if err != nil {
+			logger.GlobalLogger.Errorf("Failure %d - Failed to open submodule: %v", index+1, err)
+			continue
+		}
+		if fileRepo != repo {
+			logger.GlobalLogger.Verbosef("Failure %d - %s belongs to submodule at %s", index+1, relPath, fileRepo.Path())
+		}
+
+		// Get Git commit history for the affected file
+		commitHistory, err := fileRepo.GetEnhancedFileHistory(fileRelPath, opts.commitDepth)
+		if err != nil {
+			logger.GlobalLogger.Errorf("Failure %d - Failed to get commit history: %v", index+1, err)
+			return nil, err
+		}
+
+		// Log the commit information
+		for _, commit := range commitHistory {
+			logger.GlobalLogger.Verbosef("Failure %d - Related commit for %s: %s by %s at %s",
+				index+1,
+				failure.TestName,
+				commit.Hash[:7],
+				commit.Author,
+				commit.Date.Format("2006-01-02"),
+			)
+			logger.GlobalLogger.Debugf("Failure %d - Commit message: %s", index+1, commit.Message)
+			logger.GlobalLogger.Debugf("Failure %d - Files changed: %v", index+1, commit.Changes)
+		}
+
+		// Get line-specific changes if we have a line number
+		if failure.LineNumber > 0 {
+			// Get the exact line changes
+			lineChanges, err := fileRepo.GetLineChanges(fileRelPath, failure.LineNumber)
+			if err != nil {
+				logger.GlobalLogger.Errorf("Failure %d - Failed to get line changes: %v", index+1, err)
+			} else {
+				logger.GlobalLogger.Debugf("Failure %d - Line changes:\n%s", index+1, lineChanges)
+				failure.CodeChanges = lineChanges
+			}
+
+			// Get commits that modified this line
+			lineCommits, err := fileRepo.GetCommitsAffectingLines(fileRelPath, []int{failure.LineNumber}, opts.commitDepth)
+			if err != nil {
+				logger.GlobalLogger.Debugf("Failure %d - Failed to get line-specific commits: %v", index+1, err)
+			} else {
+				failure.RelatedCommits = lineCommits
+				for _, commit := range lineCommits {
+					logger.GlobalLogger.Verbosef("Failure %d - Line %d modified in commit %s: %s",
+						index+1,
+						failure.LineNumber,
+						commit.Hash[:7],
+						strings.Split(commit.Message, "\n")[0],
+					)
+				}
+			}
+		}
+
+		// Analyze the remaining project frames of the stack trace
+		analyzeStackFrames(repo, failure, index, opts.frameDepth, opts.commitDepth)
+
+		// Intersect uncommitted and branch changes with the stack trace files,
+		// uncommitted changes being the most likely suspects
+		suspects := findSuspectChanges(repo, failure, worktreeDiff)
+		for i := range suspects {
+			suspects[i].Uncommitted = true
+		}
+		failure.SuspectChanges = append(suspects, findSuspectChanges(repo, failure, branchDiff)...)
+		logger.GlobalLogger.Verbosef("Failure %d - %d changed file(s) on the stack trace", index+1, len(failure.SuspectChanges))
+
+		failure.Contacts = suggestContacts(repo, codeOwners, failure, opts.frameDepth)
+		logger.GlobalLogger.Verbosef("Failure %d - %d suggested contact(s)", index+1, len(failure.Contacts))
+		for _, contact := range failure.Contacts {
+			logger.GlobalLogger.Debugf("Failure %d - Contact %s: %s", index+1, contact.Name, strings.Join(contact.Reasons, "; "))
+		}
+	}
+
+	return repo, nil
+}
+
+// Gathers line changes and related commits for the top project stack frames
+func analyzeStackFrames(repo *git.Repository, failure *parsers.TestFailure, index int, frameDepth int, commitDepth int) {
+	for i := range failure.StackFrames {
+		if i >= frameDepth {
+			break
+		}
+		frame := &failure.StackFrames[i]
+
+		// Reuse the results already gathered for the failure location
+		if frame.Location() == failure.Location {
+			frame.CodeChanges = failure.CodeChanges
+			frame.RelatedCommits = failure.RelatedCommits
+			continue
+		}
+
+		relPath, err := git.NormalizeTestPath(frame.Location(), repo.Path())
+		if err != nil {
+			logger.GlobalLogger.Debugf("Failure %d - Failed to normalize frame path %s: %v", index+1, frame.File, err)
+			continue
+		}
+
+		logger.GlobalLogger.Debugf("Failure %d - Analyzing stack frame %d: %s:%d", index+1, i+1, relPath, frame.LineNumber)
+
+		fileRepo, fileRelPath, err := repo.RepositoryFor(relPath)
+		if err != nil {
+			logger.GlobalLogger.Debugf("Failure %d - Failed to open submodule for frame %d: %v", index+1, i+1, err)
+			continue
+		}
+
+		lineChanges, err := fileRepo.GetLineChanges(fileRelPath, frame.LineNumber)
+		if err != nil {
+			logger.GlobalLogger.Debugf("Failure %d - Failed to get line changes for frame %d: %v", index+1, i+1, err)
+		} else {
+			frame.CodeChanges = lineChanges
+		}
+
+		lineCommits, err := fileRepo.GetCommitsAffectingLines(fileRelPath, []int{frame.LineNumber}, commitDepth)
+		if err != nil {
+			logger.GlobalLogger.Debugf("Failure %d - Failed to get line-specific commits for frame %d: %v", index+1, i+1, err)
+		} else {
+			frame.RelatedCommits = lineCommits
+		}
+	}
+}
+
+// Selects the changed files that appear on the failure's stack trace
+func findSuspectChanges(repo *git.Repository, failure *parsers.TestFailure, diffs []git.FileDiff) []parsers.SuspectChange {
+	if len(diffs) == 0 {
+		return nil
+	}
+
+	// Map repo-relative paths to the stack trace lines within them
+	stackLines := make(map[string][]int)
+	var order []string
+	addLocation := func(location string, line int) {
+		relPath, err := git.NormalizeTestPath(location, repo.Path())
+		if err != nil {
+			return
+		}
+		if _, ok := stackLines[relPath]; !ok {
+			order = append(order, relPath)
+		}
+		if line > 0 {
+			stackLines[relPath] = append(stackLines[relPath], line)
+		}
+	}
+
+	if failure.Location != "" {
+		addLocation(failure.Location, failure.LineNumber)
+	}
+	for _, frame := range failure.StackFrames {
+		addLocation(frame.Location(), frame.LineNumber)
+	}
+
+	var suspects []parsers.SuspectChange
+	for _, path := range order {
+		for _, fileDiff := range diffs {
+			if fileDiff.Path == path {
+				suspects = append(suspects, parsers.SuspectChange{
+					Diff:  fileDiff,
+					Lines: stackLines[path],
+				})
+			}
+		}
+	}
+
+	return suspects
+}
+
+// Combines CODEOWNERS entries for the stack trace and suspect files with the
+// blame authors of the failing lines
+func suggestContacts(repo *git.Repository, codeOwners *owners.CodeOwners, failure *parsers.TestFailure, frameDepth int) []owners.Contact {
+	contacts := owners.NewContacts()
+
+	addOwners := func(relPath string) {
+		rule := codeOwners.Match(relPath)
+		if rule == nil {
+			return
+		}
+		for _, owner := range rule.Owners {
+			contacts.Add(owner, fmt.Sprintf("owns %s (%s:%d)", relPath, codeOwners.Path, rule.Line))
+		}
+	}
+
+	addAuthor := func(location string, line int) {
+		relPath, err := git.NormalizeTestPath(location, repo.Path())
+		if err != nil {
+			return
+		}
+		addOwners(relPath)
+
+		fileRepo, fileRelPath, err := repo.RepositoryFor(relPath)
+		if err != nil || line <= 0 {
+			return
+		}
+
+		blame, err := fileRepo.GetBlame(fileRelPath)
+		if err != nil || line > len(blame.Lines) {
+			return
+		}
+
+		blameLine := blame.Lines[line-1]
+		if blameLine.Uncommitted {
+			return
+		}
+		contacts.Add(
+			fmt.Sprintf("%s <%s>", blameLine.AuthorName, blameLine.Author),
+			fmt.Sprintf("last changed %s:%d in %s", relPath, line, blameLine.Hash.String()[:7]),
+		)
+	}
+
+	if failure.Location != "" {
+		addAuthor(failure.Location, failure.LineNumber)
+	}
+	for i, frame := range failure.StackFrames {
+		if i >= frameDepth {
+			break
+		}
+		addAuthor(frame.Location(), frame.LineNumber)
+	}
+
+	for _, suspect := range failure.SuspectChanges {
+		addOwners(suspect.Diff.Path)
+	}
+
+	// Commit paths are relative to the repository of the failing file, which
+	// is a submodule of the superproject for vendored code
+	prefix := ""
+	if relPath, err := git.NormalizeTestPath(failure.Location, repo.Path()); err == nil {
+		if _, fileRelPath, err := repo.RepositoryFor(relPath); err == nil {
+			prefix = strings.TrimSuffix(relPath, fileRelPath)
+		}
+	}
+	for _, commit := range failure.RelatedCommits {
+		for _, path := range commit.Changes {
+			addOwners(prefix + path)
+		}
+	}
+
+	return contacts.List()
+}
@@ -0,0 +1,100 @@
+package analyze
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/anthonydip/sherlock/internal/git"
+	"github.com/anthonydip/sherlock/internal/logger"
+	"github.com/anthonydip/sherlock/internal/owners"
+	"github.com/anthonydip/sherlock/internal/parsers"
+)
+
+func TestMain(m *testing.M) {
+	logger.GlobalLogger = logger.New(false, false, false)
+	os.Exit(m.Run())
+}
+
+// Writes the files and commits them with a fixed identity
+func commitFixture(t *testing.T, dir string, message string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, args := range [][]string{{"add", "--all"}, {"commit", "--quiet", "-m", message}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_CONFIG_GLOBAL=/dev/null",
+			"GIT_CONFIG_NOSYSTEM=1",
+			"GIT_AUTHOR_NAME=Fixture",
+			"GIT_AUTHOR_EMAIL=fixture@example.com",
+			"GIT_COMMITTER_NAME=Fixture",
+			"GIT_COMMITTER_EMAIL=fixture@example.com",
+		)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+		}
+	}
+}
+
+func TestSuggestContactsOnlyOwnersOfChangedFiles(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := exec.Command("git", "init", "--quiet", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, output)
+	}
+
+	// The related commit only touches the file owned by @a, while the tree
+	// it creates also holds the file owned by @b
+	commitFixture(t, dir, "initial", map[string]string{
+		"CODEOWNERS": "/src/ @a\n/lib/ @b\n",
+		"lib/b.js":   "module.exports = 1\n",
+	})
+	commitFixture(t, dir, "add a", map[string]string{"src/a.js": "throw new Error('a')\n"})
+
+	repo, err := git.OpenRepository(dir, 5)
+	if err != nil {
+		t.Fatalf("OpenRepository: %v", err)
+	}
+	codeOwners, err := owners.Load(dir)
+	if err != nil {
+		t.Fatalf("owners.Load: %v", err)
+	}
+
+	commits, err := repo.GetCommitsAffectingLines("src/a.js", []int{1}, 5)
+	if err != nil {
+		t.Fatalf("GetCommitsAffectingLines: %v", err)
+	}
+	if len(commits) != 1 || strings.Join(commits[0].Changes, ",") != "src/a.js" {
+		t.Fatalf("related commit changes = %v, want [src/a.js]", commits)
+	}
+
+	failure := &parsers.TestFailure{
+		Location:       filepath.Join(dir, "src", "a.js"),
+		LineNumber:     1,
+		RelatedCommits: commits,
+	}
+
+	var names []string
+	for _, contact := range suggestContacts(repo, codeOwners, failure, 0) {
+		names = append(names, contact.Name)
+	}
+	if !slices.Contains(names, "@a") || slices.Contains(names, "@b") {
+		t.Errorf("suggested contacts = %v, want @a and not @b", names)
+	}
+}
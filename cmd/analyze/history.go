@@ -0,0 +1,207 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anthonydip/sherlock/internal/cluster"
+	"github.com/anthonydip/sherlock/internal/git"
+	"github.com/anthonydip/sherlock/internal/history"
+	"github.com/anthonydip/sherlock/internal/logger"
+	"github.com/anthonydip/sherlock/internal/parsers"
+	"github.com/anthonydip/sherlock/internal/source"
+)
+
+// Results of the current run, recorded to recognize flaky tests
+type runHistory struct {
+	store   *history.Store
+	repo    *git.Repository
+	run     string
+	records []history.Record
+
+	// History keys of the tests by their file and name in the test output
+	keys map[string]string
+}
+
+// Builds the history records of this run. Returns nil with --no-history or
+// when no history file can be located.
+func newRunHistory(opts options, results []parsers.TestResult, failures []parsers.TestFailure) *runHistory {
+	if opts.noHistory {
+		logger.GlobalLogger.Verbosef("--no-history used, not recording test results")
+		return nil
+	}
+
+	// History is kept even for runs that pass, before Git enrichment
+	var repo *git.Repository
+	if !opts.noGit {
+		repo, _ = git.OpenRepository(filepath.Dir(opts.testOutput), opts.gitDepth)
+	}
+	root := projectRoot(opts, repo)
+
+	store, err := openHistory(repo, root)
+	if err != nil {
+		logger.GlobalLogger.Warnf("Failed to locate test history: %v", err)
+		return nil
+	}
+
+	h := &runHistory{store: store, repo: repo, keys: make(map[string]string)}
+
+	var commit string
+	var dirty bool
+	if repo != nil {
+		if commit, err = repo.HeadCommit(); err != nil {
+			logger.GlobalLogger.Debugf("Failed to resolve HEAD for history: %v", err)
+		}
+		if dirty, err = repo.IsDirty(); err != nil {
+			logger.GlobalLogger.Debugf("Failed to check repo status for history: %v", err)
+		}
+	}
+
+	// Paths are stored relative to the repository so runs on other machines match
+	base := root
+	if repo != nil {
+		base = repo.Path()
+	}
+	resolver := source.NewResolver(base)
+	relative := func(path string) string {
+		if resolved, err := resolver.Resolve(path); err == nil {
+			if rel, err := filepath.Rel(base, resolved); err == nil && !strings.HasPrefix(rel, "..") {
+				return filepath.ToSlash(rel)
+			}
+		}
+		return filepath.ToSlash(path)
+	}
+
+	// Failures by test, for their signature and stack trace files
+	failed := make(map[string]parsers.TestFailure)
+	for _, failure := range failures {
+		key := failure.File + "::" + failure.TestName
+		if _, ok := failed[key]; !ok {
+			failed[key] = failure
+		}
+	}
+
+	h.run = runID(opts.testOutput, commit)
+	now := time.Now().UTC()
+	for _, result := range results {
+		record := history.Record{
+			Run:    h.run,
+			Time:   now,
+			Commit: commit,
+			Dirty:  dirty,
+			File:   relative(result.File),
+			Test:   result.TestName,
+			Passed: result.Passed,
+		}
+
+		if failure, ok := failed[result.File+"::"+result.TestName]; ok && !result.Passed {
+			record.Signature = cluster.Signature(failure)
+			for _, frame := range failure.StackFrames {
+				record.Files = append(record.Files, relative(frame.File))
+			}
+		}
+
+		h.records = append(h.records, record)
+		h.keys[result.File+"::"+result.TestName] = record.Key()
+	}
+
+	return h
+}
+
+// Identifies the run by its test output and commit. Output that cannot be
+// read again is recorded as a new run.
+func runID(testOutput string, commit string) string {
+	file, err := os.Open(testOutput)
+	if err != nil {
+		logger.GlobalLogger.Debugf("Failed to read test output for the run ID: %v", err)
+		return history.NewRunID()
+	}
+	defer file.Close()
+
+	run, err := history.RunID(file, commit)
+	if err != nil {
+		logger.GlobalLogger.Debugf("Failed to read test output for the run ID: %v", err)
+		return history.NewRunID()
+	}
+	return run
+}
+
+// Keeps history in the repository's .git directory, or in the user cache
+// directory outside Git
+func openHistory(repo *git.Repository, root string) (*history.Store, error) {
+	if repo != nil && repo.HistoryPath() != "" {
+		return history.NewStore(repo.HistoryPath()), nil
+	}
+
+	path, err := history.DefaultPath(root)
+	if err != nil {
+		return nil, err
+	}
+	return history.NewStore(path), nil
+}
+
+// Notes on each failure whether its test has failed intermittently before
+func (h *runHistory) markFlaky(failures []parsers.TestFailure) {
+	if h == nil {
+		return
+	}
+
+	past, err := h.store.Load()
+	if err != nil {
+		logger.GlobalLogger.Warnf("Failed to read test history: %v", err)
+		return
+	}
+
+	var changed history.ChangeFunc
+	if h.repo != nil {
+		changed = h.repo.ChangedFiles
+	}
+
+	// The current run counts towards the failure rate, once if the same test
+	// output was analyzed before
+	records := past
+	if !history.Recorded(past, h.run) {
+		records = append(records, h.records...)
+	}
+
+	flaky := make(map[string]history.Flakiness)
+	for _, stats := range history.Detect(records, changed) {
+		flaky[stats.Key()] = stats
+	}
+
+	for i := range failures {
+		stats, ok := flaky[h.keys[failures[i].File+"::"+failures[i].TestName]]
+		if !ok {
+			continue
+		}
+
+		failures[i].Flaky = stats.Summary()
+		logger.GlobalLogger.Warnf("Failure %d - Test is flaky: %s", i+1, failures[i].Flaky)
+	}
+}
+
+// Appends this run's results to the history unless the same test output was
+// recorded before. Failing to record history does not fail the analysis.
+func (h *runHistory) save() {
+	if h == nil {
+		return
+	}
+
+	past, err := h.store.Load()
+	if err != nil {
+		logger.GlobalLogger.Warnf("Failed to read test history: %v", err)
+		return
+	}
+	if history.Recorded(past, h.run) {
+		logger.GlobalLogger.Debugf("Test results of run %s are already recorded in %s", h.run, h.store.Path())
+		return
+	}
+
+	if err := h.store.Append(h.records); err != nil {
+		logger.GlobalLogger.Warnf("Failed to record test history: %v", err)
+		return
+	}
+	logger.GlobalLogger.Debugf("Recorded %d test result(s) in %s", len(h.records), h.store.Path())
+}
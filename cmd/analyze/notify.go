@@ -0,0 +1,67 @@
+package analyze
+
+import (
+	"path/filepath"
+
+	"github.com/anthonydip/sherlock/internal/ci"
+	"github.com/anthonydip/sherlock/internal/config"
+	"github.com/anthonydip/sherlock/internal/git"
+	"github.com/anthonydip/sherlock/internal/logger"
+	"github.com/anthonydip/sherlock/internal/notify"
+	"github.com/anthonydip/sherlock/internal/report"
+)
+
+// Loads --config, or the configuration in the project under test
+func loadConfig(opts options) (*config.Config, error) {
+	path := opts.configPath
+	if path == "" {
+		var repo *git.Repository
+		if !opts.noGit {
+			repo, _ = git.OpenRepository(filepath.Dir(opts.testOutput), opts.gitDepth)
+		}
+		path = filepath.Join(projectRoot(opts, repo), config.DefaultPath)
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		logger.GlobalLogger.Errorf("Failed to load configuration %s: %v", path, err)
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Sends the failure summary to the configured channels. Notifications are
+// best effort, so errors are only reported.
+func sendNotifications(opts options, cfg *config.Config, rep *report.Report) {
+	if opts.noNotify {
+		if len(cfg.Notifications) > 0 {
+			logger.GlobalLogger.Verbosef("--no-notify used, skipping notifications")
+		}
+		return
+	}
+	if len(cfg.Notifications) == 0 || len(rep.Failures) == 0 {
+		return
+	}
+
+	event := notify.Event{Report: rep, Branch: ci.Branch()}
+	if !opts.noGit {
+		if repo, err := git.OpenRepository(filepath.Dir(opts.testOutput), opts.gitDepth); err == nil {
+			if event.Branch == "" {
+				event.Branch, _ = repo.CurrentBranch()
+			}
+			event.Commit, _ = repo.HeadCommit()
+		}
+	}
+
+	for _, channel := range cfg.Notifications {
+		sent, err := notify.Send(channel, event)
+		switch {
+		case err != nil:
+			logger.GlobalLogger.Warnf("Failed to notify %s: %v", channel, err)
+		case sent:
+			logger.GlobalLogger.Verbosef("Notified %s", channel)
+		default:
+			logger.GlobalLogger.Debugf("Nothing to notify %s about on branch '%s'", channel, event.Branch)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anthonydip/sherlock/internal/ai"
+	"github.com/anthonydip/sherlock/internal/ai/message"
+	"github.com/anthonydip/sherlock/internal/git"
+	"github.com/anthonydip/sherlock/internal/logger"
+	"github.com/anthonydip/sherlock/internal/parsers"
+	"github.com/anthonydip/sherlock/internal/report"
+	"github.com/anthonydip/sherlock/internal/source"
+)
+
+// A patch that fails to apply is sent back to the AI once
+const maxPatchAttempts = 2
+
+// Files shown to the AI per failure: the failing file and its stack frames
+const maxPatchFiles = 3
+
+// Asks the AI to turn each analysis into a patch, keeping only patches that
+// apply cleanly to the working tree, and verifies them when a verifier is
+// given. The repository itself is not modified.
+func proposePatches(aiOpts ai.AIOptions, repo *git.Repository, resolver *source.Resolver, failures []parsers.TestFailure, rep *report.Report, verify *verifier) {
+	client, err := ai.NewAIClient(aiOpts)
+	if err != nil {
+		logger.GlobalLogger.Warnf("Failed to create AI client, skipping patch proposals: %v", err)
+		return
+	}
+
+	proposed, verified := 0, 0
+	seen := make(map[int]bool)
+	for index := range rep.Failures {
+		failure := &rep.Failures[index]
+
+		// Members of a cluster share the patch of its first failure
+		if failure.Cluster > 0 {
+			if seen[failure.Cluster] {
+				continue
+			}
+			seen[failure.Cluster] = true
+		}
+
+		// Known issues are documented with their own fix
+		if failure.KnownIssue != "" {
+			continue
+		}
+
+		analysis := rep.AnalysisFor(*failure)
+		if analysis == "" {
+			continue
+		}
+
+		files := patchFiles(repo, resolver, failures[index])
+		if len(files) == 0 {
+			logger.GlobalLogger.Verbosef("Failure %d - No source files in the repository to patch", index+1)
+			continue
+		}
+
+		session := newPatchSession(client, repo, failures[index], analysis)
+		patch, err := session.request(ai.GeneratePatchPrompt(files))
+		if err != nil {
+			logger.GlobalLogger.Warnf("Failure %d - No patch proposed: %v", index+1, err)
+			continue
+		}
+		if patch == "" {
+			logger.GlobalLogger.Verbosef("Failure %d - AI proposed no patch", index+1)
+			continue
+		}
+
+		if verify != nil {
+			patch, failure.Verification = verify.verify(session, patch, rep.ClusterTests(*failure), index+1)
+			if failure.Verification == report.VerificationPassed {
+				verified++
+			}
+		}
+
+		failure.Patch = patch
+		proposed++
+	}
+
+	if proposed > 0 {
+		logger.GlobalLogger.Successf("Proposed %d patch(es), apply them with 'sherlock fix'", proposed)
+	}
+	if verify != nil && proposed > 0 {
+		logger.GlobalLogger.Successf("Verified %d of %d patch(es) by rerunning the failing tests", verified, proposed)
+	}
+}
+
+// Conversation continuing the analysis of a failure with requests for patches
+type patchSession struct {
+	client   ai.AIClient
+	repo     *git.Repository
+	messages []ai.Message
+}
+
+func newPatchSession(client ai.AIClient, repo *git.Repository, failure parsers.TestFailure, analysis string) *patchSession {
+	return &patchSession{
+		client: client,
+		repo:   repo,
+		messages: []ai.Message{
+			{Role: message.User, Content: ai.GeneratePrompt(failure)},
+			{Role: message.Assistant, Content: analysis},
+		},
+	}
+}
+
+// Sends a request for a patch, sending the error back when the patch does not
+// apply. Returns an empty patch when the AI declines.
+func (s *patchSession) request(prompt string) (string, error) {
+	s.messages = append(s.messages, ai.Message{Role: message.User, Content: prompt})
+
+	var err error
+	for attempt := 1; attempt <= maxPatchAttempts; attempt++ {
+		var answer string
+		answer, err = s.client.Chat(s.messages)
+		if err != nil {
+			return "", err
+		}
+		s.messages = append(s.messages, ai.Message{Role: message.Assistant, Content: answer})
+
+		patch := ai.ExtractPatch(answer)
+		if patch == "" {
+			return "", nil
+		}
+
+		if err = s.repo.CheckPatch(patch); err == nil {
+			return patch, nil
+		}
+		logger.GlobalLogger.Debugf("Patch attempt %d does not apply: %v", attempt, err)
+
+		if attempt < maxPatchAttempts {
+			s.messages = append(s.messages, ai.Message{Role: message.User, Content: ai.GeneratePatchRetryPrompt(err.Error())})
+		}
+	}
+
+	return "", err
+}
+
+// Reads the failing file and the files of its stack frames that belong to the
+// repository, with paths relative to its root as the patch must use
+func patchFiles(repo *git.Repository, resolver *source.Resolver, failure parsers.TestFailure) []string {
+	var candidates []string
+	if failure.Location != "" {
+		candidates = append(candidates, source.LocationPath(failure.Location))
+	}
+	for _, frame := range failure.StackFrames {
+		candidates = append(candidates, frame.File)
+	}
+
+	var files []string
+	seen := make(map[string]bool)
+	for _, candidate := range candidates {
+		if len(files) >= maxPatchFiles {
+			break
+		}
+
+		path, err := resolver.Resolve(candidate)
+		if err != nil {
+			continue
+		}
+
+		relative, err := filepath.Rel(repo.Path(), path)
+		if err != nil || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+			continue
+		}
+		relative = filepath.ToSlash(relative)
+		if seen[relative] {
+			continue
+		}
+		seen[relative] = true
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		files = append(files, ai.GenerateFilePrompt(relative, string(content)))
+	}
+
+	return files
+}
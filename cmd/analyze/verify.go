@@ -0,0 +1,126 @@
+package analyze
+
+import (
+	"fmt"
+
+	"github.com/anthonydip/sherlock/internal/ai"
+	"github.com/anthonydip/sherlock/internal/config"
+	"github.com/anthonydip/sherlock/internal/git"
+	"github.com/anthonydip/sherlock/internal/logger"
+	"github.com/anthonydip/sherlock/internal/report"
+	"github.com/anthonydip/sherlock/internal/testrun"
+)
+
+// Patches tried per failure until the tests pass
+const defaultVerifyRounds = 3
+
+// Checks that patches can be verified before spending time on the analysis
+func checkVerify(opts options, cfg *config.Config) error {
+	if !opts.verifyPatch {
+		return nil
+	}
+	if opts.noGit {
+		return fmt.Errorf("--verify-patch requires Git, it cannot be combined with --no-git")
+	}
+	if cfg.Test.Command == "" {
+		return fmt.Errorf("--verify-patch requires a test command, set test.command in %s", config.DefaultPath)
+	}
+	if _, err := cfg.Test.RunnerName(); err != nil {
+		return err
+	}
+	if opts.verifyRounds < 1 {
+		return fmt.Errorf("--verify-rounds must be at least 1")
+	}
+	return nil
+}
+
+// Reruns the failing tests with a patch applied in a temporary worktree of
+// HEAD, leaving the user's checkout untouched
+type verifier struct {
+	repo   *git.Repository
+	test   testrun.Config
+	head   string
+	rounds int
+}
+
+// Returns the verifier for --verify-patch, nil when patches are not verified
+func newPatchVerifier(opts options, cfg *config.Config, repo *git.Repository) *verifier {
+	if !opts.verifyPatch {
+		return nil
+	}
+
+	head, err := repo.HeadCommit()
+	if err != nil {
+		logger.GlobalLogger.Warnf("Cannot verify patches without a commit at HEAD: %v", err)
+		return nil
+	}
+	return &verifier{repo: repo, test: cfg.Test, head: head, rounds: opts.verifyRounds}
+}
+
+// Runs the patch against the tests, asking the AI for another patch with the
+// new failure output while they still fail. Returns the last patch tried and
+// its verification status, empty when the tests could not be run.
+func (v *verifier) verify(session *patchSession, patch string, tests []string, number int) (string, string) {
+	dir, cleanup, err := v.repo.AddTempWorktree(v.head)
+	if err != nil {
+		logger.GlobalLogger.Warnf("Failure %d - Cannot verify patch, failed to create worktree: %v", number, err)
+		return patch, ""
+	}
+	defer cleanup()
+
+	if output, err := v.test.RunSetup(dir); err != nil {
+		logger.GlobalLogger.Warnf("Failure %d - Cannot verify patch, setup failed: %v", number, err)
+		logger.GlobalLogger.Debugf("Setup output:\n%s", output)
+		return patch, ""
+	}
+
+	// Tests passing without the patch would verify any patch
+	baseline, err := v.test.Run(dir, tests)
+	if err != nil {
+		logger.GlobalLogger.Warnf("Failure %d - Cannot verify patch, failed to run tests: %v", number, err)
+		return patch, ""
+	}
+	if baseline.Passed {
+		logger.GlobalLogger.Warnf("Failure %d - Cannot verify patch, the tests pass at HEAD without it", number)
+		return patch, ""
+	}
+
+	command, _ := v.test.FilteredCommand(tests)
+	for round := 1; ; round++ {
+		if err := git.ApplyPatchIn(dir, patch); err != nil {
+			logger.GlobalLogger.Warnf("Failure %d - Cannot verify patch, it does not apply at HEAD: %v", number, err)
+			return patch, ""
+		}
+
+		result, err := v.test.Run(dir, tests)
+		if revertErr := git.RevertPatchIn(dir, patch); revertErr != nil && err == nil {
+			err = fmt.Errorf("failed to revert patch: %w", revertErr)
+		}
+		if err != nil {
+			logger.GlobalLogger.Warnf("Failure %d - Cannot verify patch: %v", number, err)
+			return patch, ""
+		}
+
+		if result.Passed {
+			logger.GlobalLogger.Verbosef("Failure %d - Tests pass with the patch (round %d/%d)", number, round, v.rounds)
+			return patch, report.VerificationPassed
+		}
+		logger.GlobalLogger.Verbosef("Failure %d - Tests still fail with the patch (round %d/%d)", number, round, v.rounds)
+		logger.GlobalLogger.Debugf("Test output:\n%s", result.Output)
+
+		if round == v.rounds {
+			return patch, report.VerificationFailed
+		}
+
+		next, err := session.request(ai.GenerateVerifyRetryPrompt(command, result.Output))
+		if err != nil {
+			logger.GlobalLogger.Warnf("Failure %d - No corrected patch proposed: %v", number, err)
+			return patch, report.VerificationFailed
+		}
+		if next == "" {
+			logger.GlobalLogger.Verbosef("Failure %d - AI proposed no corrected patch", number)
+			return patch, report.VerificationFailed
+		}
+		patch = next
+	}
+}
@@ -0,0 +1,386 @@
+package bisect
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/anthonydip/sherlock/internal/ai"
+	"github.com/anthonydip/sherlock/internal/cli"
+	"github.com/anthonydip/sherlock/internal/git"
+	"github.com/anthonydip/sherlock/internal/logger"
+	"github.com/anthonydip/sherlock/internal/testrun"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Exit code used by test commands to mark a commit as untestable, as in git bisect
+const skipExitCode = 125
+
+type testResult int
+
+const (
+	resultGood testResult = iota
+	resultBad
+	resultSkip
+)
+
+func NewBisectCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bisect --good <ref> -- <test-command>",
+		Short: "Find the commit that introduced a test failure",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				fmt.Fprintf(os.Stderr, "error: no test command specified\n")
+				fmt.Fprint(os.Stderr, cli.FormatUsage(cmd, "-- <test-command>", generateOptionGroups(cmd)))
+				return fmt.Errorf("Requires a test command")
+			}
+			return nil
+		},
+	}
+
+	// Bisect flags
+	cmd.Flags().String("good", "", "known-good ref where the test passes (required)")
+	cmd.Flags().String("bad", "HEAD", "ref where the test fails (default: HEAD)")
+	cmd.Flags().String("setup", "", "command to run in each worktree before the test (e.g. npm ci)")
+	cmd.Flags().Duration("timeout", 10*time.Minute, "maximum duration of each test run (default: 10m)")
+	cmd.Flags().Int("git-depth", 5, "maximum parent directory levels to search for .git (default: 5)")
+
+	// AI flags
+	cli.AddAIFlags(cmd)
+	cmd.Flags().Bool("no-ai", false, "only report the first bad commit, skipping AI analysis")
+	cmd.Flags().StringP("output", "o", "", "write output to file (default: .md)")
+
+	cmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		option := cli.StripInvalidFlag(err)
+
+		fmt.Fprintf(os.Stderr, "unknown option: %s\n", option)
+		fmt.Fprint(os.Stderr, cli.FormatUsage(cmd, "-- <test-command>", generateOptionGroups(cmd)))
+		return nil
+	})
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		goodRef, _ := cmd.Flags().GetString("good")
+		badRef, _ := cmd.Flags().GetString("bad")
+		setup, _ := cmd.Flags().GetString("setup")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		depth, _ := cmd.Flags().GetInt("git-depth")
+		noAI, _ := cmd.Flags().GetBool("no-ai")
+		outputPath, _ := cmd.Flags().GetString("output")
+
+		if goodRef == "" {
+			logger.GlobalLogger.Errorf("No known-good ref specified (use --good)")
+			return fmt.Errorf("missing --good ref")
+		}
+
+		var aiOpts ai.AIOptions
+		if !noAI {
+			var err error
+			aiOpts, err = cli.GetAIOptions(cmd)
+			if err != nil {
+				logger.GlobalLogger.Errorf("%s", err)
+				return err
+			}
+		}
+
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+
+		repo, err := git.OpenRepository(wd, depth)
+		if err != nil {
+			if errors.Is(err, git.ErrNotAGitRepository) {
+				logger.GlobalLogger.Errorf("Not running in a Git repository")
+			} else {
+				logger.GlobalLogger.Errorf("Git error: %v", err)
+			}
+			return fmt.Errorf("git error: %v", err)
+		}
+
+		good, err := repo.ResolveCommit(goodRef)
+		if err != nil {
+			logger.GlobalLogger.Errorf("Failed to resolve good ref: %v", err)
+			return err
+		}
+
+		bad, err := repo.ResolveCommit(badRef)
+		if err != nil {
+			logger.GlobalLogger.Errorf("Failed to resolve bad ref: %v", err)
+			return err
+		}
+
+		candidates, err := repo.CommitsBetween(good, bad)
+		if err != nil {
+			logger.GlobalLogger.Errorf("Failed to list commits: %v", err)
+			return err
+		}
+		if len(candidates) == 0 {
+			logger.GlobalLogger.Errorf("Good and bad refs point to the same commit")
+			return fmt.Errorf("nothing to bisect")
+		}
+
+		testCommand := strings.Join(args, " ")
+		logger.GlobalLogger.Verbosef("Bisecting %d commit(s) between %s and %s with: %s",
+			len(candidates), good.Hash.String()[:7], bad.Hash.String()[:7], testCommand)
+
+		runner := &testRunner{
+			repo:    repo,
+			args:    args,
+			setup:   setup,
+			timeout: timeout,
+			outputs: make(map[string]string),
+		}
+
+		// Confirm the test passes at the good ref and the failure reproduces at
+		// the bad ref before searching for its origin
+		result, err := runner.run(good)
+		if err != nil {
+			return err
+		}
+		switch result {
+		case resultBad:
+			logger.GlobalLogger.Errorf("Test also fails at %s, use an older --good ref", goodRef)
+			return fmt.Errorf("test fails at good ref")
+		case resultSkip:
+			logger.GlobalLogger.Errorf("Test cannot run at %s", goodRef)
+			return fmt.Errorf("test skipped at good ref")
+		}
+
+		result, err = runner.run(bad)
+		if err != nil {
+			return err
+		}
+		switch result {
+		case resultGood:
+			logger.GlobalLogger.Errorf("Test does not fail at %s, nothing to bisect", badRef)
+			return fmt.Errorf("test passes at bad ref")
+		case resultSkip:
+			logger.GlobalLogger.Errorf("Test cannot run at %s", badRef)
+			return fmt.Errorf("test skipped at bad ref")
+		}
+
+		culprit, skipped, err := bisect(runner, candidates)
+		if err != nil {
+			logger.GlobalLogger.Errorf("Bisect failed: %v", err)
+			return err
+		}
+
+		// Untestable commits right before the culprit may have introduced the
+		// failure instead, as git bisect reports
+		if len(skipped) > 0 {
+			var possible strings.Builder
+			for _, commit := range append(skipped, culprit) {
+				fmt.Fprintf(&possible, "\n    %s %s", commit.Hash.String()[:7], strings.Split(strings.TrimSpace(commit.Message), "\n")[0])
+			}
+			logger.GlobalLogger.Warnf("First bad commit could be any of:%s\nSkipped commits could not be tested, not analyzing an uncertain culprit", possible.String())
+			return nil
+		}
+
+		info, err := repo.GetCommitInfo(culprit)
+		if err != nil {
+			logger.GlobalLogger.Errorf("Failed to read commit %s: %v", culprit.Hash.String()[:7], err)
+			return err
+		}
+
+		logger.GlobalLogger.Successf("First bad commit: %s by %s at %s\n    %s",
+			info.Hash[:7],
+			info.Author,
+			info.Date.Format("2006-01-02"),
+			strings.Split(info.Message, "\n")[0],
+		)
+		logger.GlobalLogger.Verbosef("Files changed: %v", info.Changes)
+
+		if noAI {
+			return nil
+		}
+
+		aiClient, err := ai.NewAIClient(aiOpts)
+		if err != nil {
+			logger.GlobalLogger.Errorf("Failed to create AI client: %v", err)
+			return err
+		}
+		logger.GlobalLogger.Verbosef("Initialized AI Client for %s with %s", aiOpts.Provider, aiOpts.Model)
+
+		aiResponse, err := ai.AnalyzeBisect(aiClient, info, testCommand, runner.outputs[info.Hash])
+		if err != nil {
+			logger.GlobalLogger.Errorf("AI request failed: %v", err)
+			return err
+		}
+
+		if outputPath == "" {
+			logger.GlobalLogger.Successf("%v\n", aiResponse)
+			return nil
+		}
+
+		if filepath.Ext(outputPath) != ".md" {
+			originalPath := outputPath
+			outputPath += ".md"
+			logger.GlobalLogger.Warnf("Output file should use .md extension. Changed '%s' → '%s'", originalPath, outputPath)
+		}
+
+		if err := os.WriteFile(outputPath, []byte(aiResponse), 0644); err != nil {
+			logger.GlobalLogger.Errorf("Failed to write AI response to file: %v", err)
+			return err
+		}
+		logger.GlobalLogger.Verbosef("AI analysis saved to %s", outputPath)
+
+		return nil
+	}
+
+	return cmd
+}
+
+// Binary searches the candidates (oldest first, the last one known bad) for
+// the first commit where the test fails. Also returns the skipped commits
+// between the last good commit and the culprit, any of which could be the
+// first bad commit instead.
+func bisect(runner *testRunner, candidates []*object.Commit) (*object.Commit, []*object.Commit, error) {
+	remaining := slices.Clone(candidates)
+	skipped := make(map[plumbing.Hash]bool)
+	lo, hi := 0, len(remaining)-1
+
+	for lo < hi {
+		mid := (lo + hi) / 2
+
+		result, err := runner.run(remaining[mid])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch result {
+		case resultGood:
+			lo = mid + 1
+		case resultBad:
+			hi = mid
+		case resultSkip:
+			// Drop untestable commits from the search
+			skipped[remaining[mid].Hash] = true
+			remaining = append(remaining[:mid], remaining[mid+1:]...)
+			hi--
+		}
+
+		logger.GlobalLogger.Verbosef("%d commit(s) left to test", hi-lo)
+	}
+
+	culprit := remaining[hi]
+
+	// Every commit between the last good one and the culprit was skipped
+	var possible []*object.Commit
+	for i := slices.Index(candidates, culprit) - 1; i >= 0 && skipped[candidates[i].Hash]; i-- {
+		possible = append([]*object.Commit{candidates[i]}, possible...)
+	}
+
+	return culprit, possible, nil
+}
+
+type testRunner struct {
+	repo    *git.Repository
+	args    []string
+	setup   string
+	timeout time.Duration
+
+	// Combined test output per commit hash
+	outputs map[string]string
+}
+
+// Runs the test command against a commit in a temporary worktree
+func (t *testRunner) run(commit *object.Commit) (testResult, error) {
+	hash := commit.Hash.String()
+
+	dir, cleanup, err := t.repo.AddTempWorktree(hash)
+	if err != nil {
+		logger.GlobalLogger.Errorf("Failed to create worktree for %s: %v", hash[:7], err)
+		return resultSkip, err
+	}
+	defer cleanup()
+
+	if t.setup != "" {
+		logger.GlobalLogger.Debugf("Running setup for %s: %s", hash[:7], t.setup)
+		if output, err := t.execute(dir, "sh", "-c", t.setup); err != nil {
+			logger.GlobalLogger.Warnf("Setup failed at %s, skipping commit: %v", hash[:7], err)
+			logger.GlobalLogger.Debugf("Setup output:\n%s", output)
+			return resultSkip, nil
+		}
+	}
+
+	output, err := t.execute(dir, t.args[0], t.args[1:]...)
+	t.outputs[hash] = output
+
+	var result testResult
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		result = resultGood
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == skipExitCode:
+		result = resultSkip
+	case errors.As(err, &exitErr), errors.Is(err, context.DeadlineExceeded):
+		result = resultBad
+	default:
+		logger.GlobalLogger.Errorf("Failed to run test command: %v", err)
+		return resultSkip, err
+	}
+
+	logger.GlobalLogger.Verbosef("Commit %s: %s (%s)",
+		hash[:7],
+		[]string{"good", "bad", "skip"}[result],
+		strings.Split(strings.TrimSpace(commit.Message), "\n")[0],
+	)
+
+	return result, nil
+}
+
+func (t *testRunner) execute(dir string, name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	command := testrun.Command(ctx, name, args...)
+	command.Dir = dir
+	command.Stdout = &output
+	command.Stderr = &output
+
+	err := command.Run()
+	if ctx.Err() != nil {
+		return output.String(), ctx.Err()
+	}
+	return output.String(), err
+}
+
+func generateOptionGroups(cmd *cobra.Command) []cli.FlagGroup {
+	groups := []cli.FlagGroup{
+		{
+			Name: "Bisect options",
+			Flags: []*pflag.Flag{
+				cmd.Flags().Lookup("good"),
+				cmd.Flags().Lookup("bad"),
+				cmd.Flags().Lookup("setup"),
+				cmd.Flags().Lookup("timeout"),
+				cmd.Flags().Lookup("git-depth"),
+			},
+		},
+		{
+			Name: "AI options",
+			Flags: []*pflag.Flag{
+				cmd.Flags().Lookup("api-key"),
+				cmd.Flags().Lookup("model"),
+				cmd.Flags().Lookup("ai-provider"),
+				cmd.Flags().Lookup("no-cache"),
+				cmd.Flags().Lookup("cache-ttl"),
+				cmd.Flags().Lookup("no-ai"),
+				cmd.Flags().Lookup("output"),
+			},
+		},
+	}
+
+	return groups
+}
@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"github.com/anthonydip/sherlock/internal/ai"
+	"github.com/anthonydip/sherlock/internal/logger"
+	"github.com/spf13/cobra"
+)
+
+func NewCacheCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage cached AI responses",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "clear",
+		Short: "Remove all cached AI responses",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir, err := ai.DefaultCacheDir()
+			if err != nil {
+				logger.GlobalLogger.Errorf("Failed to locate response cache: %v", err)
+				return err
+			}
+
+			removed, err := ai.ClearCache(dir)
+			if err != nil {
+				logger.GlobalLogger.Errorf("Failed to clear response cache: %v", err)
+				return err
+			}
+
+			logger.GlobalLogger.Successf("Removed %d cached response(s) from %s", removed, dir)
+			return nil
+		},
+	})
+
+	return cmd
+}
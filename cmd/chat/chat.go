@@ -0,0 +1,225 @@
+package chat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anthonydip/sherlock/internal/ai"
+	"github.com/anthonydip/sherlock/internal/ai/message"
+	"github.com/anthonydip/sherlock/internal/cli"
+	"github.com/anthonydip/sherlock/internal/logger"
+	"github.com/anthonydip/sherlock/internal/report"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Longest line read from the terminal, enough for pasted stack traces
+const maxInputLine = 1024 * 1024
+
+const helpText = `Commands:
+  /add <path>  include a file with your next message
+  /help        show this help
+  /quit        end the chat (or press Ctrl-D)`
+
+func NewChatCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "chat [report]",
+		Short: "Ask follow-up questions about a failure from a JSON report",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				fmt.Fprintf(os.Stderr, "error: no report specified\n")
+				fmt.Fprint(os.Stderr, cli.FormatUsage(cmd, "<report.json>", generateOptionGroups(cmd)))
+				return fmt.Errorf("Requires exactly 1 report")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntP("failure", "f", 1, "number of the failure in the report to discuss (default: 1)")
+	cli.AddAIFlags(cmd)
+
+	cmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		option := cli.StripInvalidFlag(err)
+
+		fmt.Fprintf(os.Stderr, "unknown option: %s\n", option)
+		fmt.Fprint(os.Stderr, cli.FormatUsage(cmd, "<report.json>", generateOptionGroups(cmd)))
+		return nil
+	})
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		number, _ := cmd.Flags().GetInt("failure")
+
+		rep, err := report.Load(args[0])
+		if err != nil {
+			logger.GlobalLogger.Errorf("Failed to read report: %v", err)
+			return err
+		}
+
+		if number < 1 || number > len(rep.Failures) {
+			logger.GlobalLogger.Errorf("Report has %d failure(s), cannot discuss failure %d", len(rep.Failures), number)
+			return fmt.Errorf("invalid failure number: %d", number)
+		}
+		failure := rep.Failures[number-1]
+
+		aiOpts, err := cli.GetAIOptions(cmd)
+		if err != nil {
+			logger.GlobalLogger.Errorf("%s", err)
+			return err
+		}
+
+		client, err := ai.NewAIClient(aiOpts)
+		if err != nil {
+			logger.GlobalLogger.Errorf("Failed to create AI client: %v", err)
+			return err
+		}
+
+		// The conversation starts from the analysis the report was built from
+		s := &session{
+			client: client,
+			root:   rep.SourceRoot,
+			out:    cmd.OutOrStdout(),
+			messages: []ai.Message{
+				{Role: message.User, Content: ai.GeneratePrompt(failure.TestFailure())},
+			},
+		}
+
+		fmt.Fprintf(s.out, "Failure %d/%d: %s\n%s\n\n", number, len(rep.Failures), failure.TestName, strings.TrimSpace(strings.Split(failure.Error, "\n")[0]))
+
+		answer := rep.AnalysisFor(failure)
+		if answer == "" {
+			logger.GlobalLogger.Verbosef("Report has no analysis for failure %d, requesting one", number)
+			if answer, err = ai.AnalyzeFailure(client, failure.TestFailure()); err != nil {
+				logger.GlobalLogger.Errorf("AI analysis failed: %v", err)
+				return err
+			}
+		}
+		s.messages = append(s.messages, ai.Message{Role: message.Assistant, Content: answer})
+		fmt.Fprintf(s.out, "%s\n\n%s\n", answer, helpText)
+
+		return s.run(cmd.InOrStdin())
+	}
+
+	return cmd
+}
+
+// Conversation about one failure
+type session struct {
+	client   ai.AIClient
+	messages []ai.Message
+
+	// Files added with /add, sent along with the next question
+	pending []string
+
+	// Directory relative paths are also looked up in
+	root string
+
+	out io.Writer
+}
+
+// Reads questions until the input ends or the user quits
+func (s *session) run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxInputLine)
+
+	for {
+		fmt.Fprint(s.out, "\n> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(s.out)
+			return scanner.Err()
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case line == "/quit" || line == "/exit":
+			return nil
+		case line == "/help":
+			fmt.Fprintln(s.out, helpText)
+		case line == "/add" || strings.HasPrefix(line, "/add "):
+			s.add(strings.TrimSpace(strings.TrimPrefix(line, "/add")))
+		case strings.HasPrefix(line, "/"):
+			fmt.Fprintf(s.out, "Unknown command %s\n%s\n", strings.Fields(line)[0], helpText)
+		default:
+			s.ask(line)
+		}
+	}
+}
+
+// Queues a file to be sent with the next question
+func (s *session) add(path string) {
+	if path == "" {
+		fmt.Fprintln(s.out, "Usage: /add <path>")
+		return
+	}
+
+	content, resolved, err := s.readFile(path)
+	if err != nil {
+		logger.GlobalLogger.Errorf("Failed to read %s: %v", path, err)
+		return
+	}
+
+	s.pending = append(s.pending, ai.GenerateFilePrompt(resolved, content))
+	fmt.Fprintf(s.out, "Added %s (%d lines), it will be sent with your next message\n", resolved, strings.Count(content, "\n")+1)
+}
+
+// Reads a file relative to the working directory or the report's source root
+func (s *session) readFile(path string) (string, string, error) {
+	content, err := os.ReadFile(path)
+	if err == nil || filepath.IsAbs(path) || s.root == "" {
+		return string(content), path, err
+	}
+
+	rooted := filepath.Join(s.root, path)
+	if content, rootErr := os.ReadFile(rooted); rootErr == nil {
+		return string(content), rooted, nil
+	}
+	return "", path, err
+}
+
+// Sends a question with any pending files and prints the answer. Failed
+// requests leave the conversation as it was so the question can be retried.
+func (s *session) ask(question string) {
+	content := question
+	if len(s.pending) > 0 {
+		content = strings.Join(s.pending, "\n") + "\n" + question
+	}
+
+	messages := append(s.messages, ai.Message{Role: message.User, Content: content})
+	answer, err := s.client.Chat(messages)
+	if err != nil {
+		logger.GlobalLogger.Errorf("AI request failed: %v", err)
+		return
+	}
+
+	s.messages = append(messages, ai.Message{Role: message.Assistant, Content: answer})
+	s.pending = nil
+	fmt.Fprintln(s.out, answer)
+}
+
+func generateOptionGroups(cmd *cobra.Command) []cli.FlagGroup {
+	groups := []cli.FlagGroup{
+		{
+			Name: "Chat options",
+			Flags: []*pflag.Flag{
+				cmd.Flags().Lookup("failure"),
+			},
+		},
+		{
+			Name: "AI options",
+			Flags: []*pflag.Flag{
+				cmd.Flags().Lookup("api-key"),
+				cmd.Flags().Lookup("model"),
+				cmd.Flags().Lookup("ai-provider"),
+				cmd.Flags().Lookup("no-cache"),
+				cmd.Flags().Lookup("cache-ttl"),
+			},
+		},
+	}
+
+	return groups
+}
@@ -0,0 +1,175 @@
+package fix
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/anthonydip/sherlock/internal/cli"
+	"github.com/anthonydip/sherlock/internal/git"
+	"github.com/anthonydip/sherlock/internal/logger"
+	"github.com/anthonydip/sherlock/internal/report"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func NewFixCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fix [report]",
+		Short: "Apply the patch proposed for a failure in a JSON report",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				fmt.Fprintf(os.Stderr, "error: no report specified\n")
+				fmt.Fprint(os.Stderr, cli.FormatUsage(cmd, "<report.json>", generateOptionGroups(cmd)))
+				return fmt.Errorf("Requires exactly 1 report")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntP("failure", "f", 1, "number of the failure in the report to fix (default: 1)")
+	cmd.Flags().String("branch", "", "commit the patch to a new branch instead of the working tree")
+	cmd.Flags().BoolP("yes", "y", false, "apply without asking for confirmation")
+	cmd.Flags().Int("git-depth", 5, "maximum parent directory levels to search for .git (default: 5)")
+
+	cmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		option := cli.StripInvalidFlag(err)
+
+		fmt.Fprintf(os.Stderr, "unknown option: %s\n", option)
+		fmt.Fprint(os.Stderr, cli.FormatUsage(cmd, "<report.json>", generateOptionGroups(cmd)))
+		return nil
+	})
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		number, _ := cmd.Flags().GetInt("failure")
+		branch, _ := cmd.Flags().GetString("branch")
+		yes, _ := cmd.Flags().GetBool("yes")
+		depth, _ := cmd.Flags().GetInt("git-depth")
+
+		rep, err := report.Load(args[0])
+		if err != nil {
+			logger.GlobalLogger.Errorf("Failed to read report: %v", err)
+			return err
+		}
+
+		if number < 1 || number > len(rep.Failures) {
+			logger.GlobalLogger.Errorf("Report has %d failure(s), cannot fix failure %d", len(rep.Failures), number)
+			return fmt.Errorf("invalid failure number: %d", number)
+		}
+		failure := rep.Failures[number-1]
+
+		patched := rep.PatchFor(failure)
+		patch := patched.Patch
+		if patch == "" {
+			logger.GlobalLogger.Errorf("Report has no patch for failure %d, run analyze with --propose-patch", number)
+			return fmt.Errorf("no patch for failure %d", number)
+		}
+
+		repo, err := openRepository(rep, depth)
+		if err != nil {
+			if errors.Is(err, git.ErrNotAGitRepository) {
+				logger.GlobalLogger.Errorf("Not running in a Git repository")
+			} else {
+				logger.GlobalLogger.Errorf("Git error: %v", err)
+			}
+			return fmt.Errorf("git error: %v", err)
+		}
+
+		out := cmd.OutOrStdout()
+		fmt.Fprintf(out, "Failure %d/%d: %s\n\n%s\n", number, len(rep.Failures), failure.TestName, strings.TrimRight(patch, "\n"))
+
+		switch patched.Verification {
+		case report.VerificationPassed:
+			logger.GlobalLogger.Successf("%s", report.VerificationNote(patched))
+		case report.VerificationFailed:
+			logger.GlobalLogger.Warnf("%s", report.VerificationNote(patched))
+		}
+
+		// The branch is created from HEAD, the working tree may have moved on
+		if branch == "" {
+			if err := repo.CheckPatch(patch); err != nil {
+				logger.GlobalLogger.Errorf("Patch no longer applies to the working tree: %v", err)
+				return err
+			}
+		}
+
+		target := "the working tree"
+		if branch != "" {
+			target = fmt.Sprintf("a new branch '%s'", branch)
+		}
+		if !yes && !confirm(cmd.InOrStdin(), out, fmt.Sprintf("\nApply the patch to %s? [y/N] ", target)) {
+			logger.GlobalLogger.Warnf("Patch not applied, no changes made")
+			return nil
+		}
+
+		if branch == "" {
+			if err := repo.ApplyPatch(patch); err != nil {
+				logger.GlobalLogger.Errorf("Failed to apply patch: %v", err)
+				return err
+			}
+			logger.GlobalLogger.Successf("Applied the patch to %s, review it with 'git diff'", repo.Path())
+			return nil
+		}
+
+		hash, err := repo.CommitPatchToBranch(patch, branch, "Fix "+failure.TestName)
+		if err != nil {
+			logger.GlobalLogger.Errorf("Failed to commit patch to branch %s: %v", branch, err)
+			return err
+		}
+		logger.GlobalLogger.Successf("Committed the patch to branch %s (%s)", branch, hash[:7])
+
+		return nil
+	}
+
+	return cmd
+}
+
+// Opens the repository the report was generated in, or the one containing
+// the working directory
+func openRepository(rep *report.Report, depth int) (*git.Repository, error) {
+	if rep.SourceRoot != "" {
+		if repo, err := git.OpenRepository(rep.SourceRoot, depth); err == nil {
+			return repo, nil
+		}
+		logger.GlobalLogger.Debugf("Report source root %s is not a repository, using the working directory", rep.SourceRoot)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return git.OpenRepository(wd, depth)
+}
+
+// Asks a yes/no question, treating anything but yes, including no input, as no
+func confirm(in io.Reader, out io.Writer, question string) bool {
+	fmt.Fprint(out, question)
+
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(out)
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func generateOptionGroups(cmd *cobra.Command) []cli.FlagGroup {
+	groups := []cli.FlagGroup{
+		{
+			Name: "Fix options",
+			Flags: []*pflag.Flag{
+				cmd.Flags().Lookup("failure"),
+				cmd.Flags().Lookup("branch"),
+				cmd.Flags().Lookup("yes"),
+				cmd.Flags().Lookup("git-depth"),
+			},
+		},
+	}
+
+	return groups
+}
@@ -0,0 +1,153 @@
+package flaky
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/anthonydip/sherlock/internal/cli"
+	"github.com/anthonydip/sherlock/internal/git"
+	"github.com/anthonydip/sherlock/internal/history"
+	"github.com/anthonydip/sherlock/internal/logger"
+	"github.com/anthonydip/sherlock/internal/report"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func NewFlakyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "flaky",
+		Short: "List tests that fail intermittently across recorded runs",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				fmt.Fprintf(os.Stderr, "error: unexpected argument %q\n", args[0])
+				fmt.Fprint(os.Stderr, cli.FormatUsage(cmd, "", generateOptionGroups(cmd)))
+				return fmt.Errorf("Accepts no arguments")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().Int("min-runs", 3, "minimum recorded runs of a test before it can be reported (default: 3)")
+	cmd.Flags().String("history-file", "", "history file to read (default: .git/sherlock/history.jsonl)")
+	cmd.Flags().Int("git-depth", 5, "maximum parent directory levels to search for .git (default: 5)")
+	cmd.Flags().String("format", report.FormatMarkdown, "output format (markdown, json)")
+
+	cmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		option := cli.StripInvalidFlag(err)
+
+		fmt.Fprintf(os.Stderr, "unknown option: %s\n", option)
+		fmt.Fprint(os.Stderr, cli.FormatUsage(cmd, "", generateOptionGroups(cmd)))
+		return nil
+	})
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		minRuns, _ := cmd.Flags().GetInt("min-runs")
+		historyFile, _ := cmd.Flags().GetString("history-file")
+		depth, _ := cmd.Flags().GetInt("git-depth")
+		format, _ := cmd.Flags().GetString("format")
+
+		if format != report.FormatMarkdown && format != report.FormatJSON {
+			logger.GlobalLogger.Errorf("unknown format '%s' (supported: markdown, json)", format)
+			return fmt.Errorf("unknown format: %s", format)
+		}
+		if format == report.FormatJSON {
+			logger.GlobalLogger.SetOutput(os.Stderr)
+		}
+
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+
+		// Without a repository only outcome changes on the same commit count
+		repo, err := git.OpenRepository(wd, depth)
+		if err != nil && !errors.Is(err, git.ErrNotAGitRepository) {
+			logger.GlobalLogger.Errorf("Git error: %v", err)
+			return fmt.Errorf("git error: %v", err)
+		}
+
+		if historyFile == "" {
+			historyFile, err = defaultHistoryFile(repo, wd)
+			if err != nil {
+				logger.GlobalLogger.Errorf("Failed to locate test history: %v", err)
+				return err
+			}
+		}
+
+		records, err := history.NewStore(historyFile).Load()
+		if err != nil {
+			logger.GlobalLogger.Errorf("Failed to read test history: %v", err)
+			return err
+		}
+		logger.GlobalLogger.Verbosef("Read %d test result(s) from %s", len(records), historyFile)
+
+		var changed history.ChangeFunc
+		if repo != nil {
+			changed = repo.ChangedFiles
+		}
+
+		var flaky []history.Flakiness
+		for _, stats := range history.Detect(records, changed) {
+			if stats.Runs >= minRuns {
+				flaky = append(flaky, stats)
+			}
+		}
+
+		if format == report.FormatJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			encoder.SetEscapeHTML(false)
+			if flaky == nil {
+				flaky = []history.Flakiness{}
+			}
+			return encoder.Encode(flaky)
+		}
+
+		if len(flaky) == 0 {
+			logger.GlobalLogger.Successf("No flaky tests found in %d recorded result(s)", len(records))
+			return nil
+		}
+
+		logger.GlobalLogger.Successf("Found %d flaky test(s)\n%s", len(flaky), formatFlaky(flaky))
+		return nil
+	}
+
+	return cmd
+}
+
+// Returns the history file analyze records to: in the repository's .git
+// directory, or in the user cache directory outside Git
+func defaultHistoryFile(repo *git.Repository, wd string) (string, error) {
+	if repo != nil && repo.HistoryPath() != "" {
+		return repo.HistoryPath(), nil
+	}
+	return history.DefaultPath(wd)
+}
+
+func formatFlaky(flaky []history.Flakiness) string {
+	var builder strings.Builder
+	for _, stats := range flaky {
+		fmt.Fprintf(&builder, "\n### %s\n`%s`: %s, %d outcome change(s) without a related code change\n",
+			stats.Test, stats.File, stats.Summary(), stats.Flips)
+	}
+	return builder.String()
+}
+
+func generateOptionGroups(cmd *cobra.Command) []cli.FlagGroup {
+	groups := []cli.FlagGroup{
+		{
+			Name: "History options",
+			Flags: []*pflag.Flag{
+				cmd.Flags().Lookup("min-runs"),
+				cmd.Flags().Lookup("history-file"),
+				cmd.Flags().Lookup("git-depth"),
+				cmd.Flags().Lookup("format"),
+			},
+		},
+	}
+
+	return groups
+}
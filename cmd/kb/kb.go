@@ -0,0 +1,152 @@
+package kb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anthonydip/sherlock/internal/ai"
+	"github.com/anthonydip/sherlock/internal/cli"
+	"github.com/anthonydip/sherlock/internal/git"
+	"github.com/anthonydip/sherlock/internal/kb"
+	"github.com/anthonydip/sherlock/internal/logger"
+	"github.com/anthonydip/sherlock/internal/report"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func NewKBCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "kb",
+		Short: "Manage the known-issues knowledge base",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(newAddCmd())
+
+	return cmd
+}
+
+func newAddCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "add [report]",
+		Short: "Promote an analysis from a JSON report into a known issue",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				fmt.Fprintf(os.Stderr, "error: no report specified\n")
+				fmt.Fprint(os.Stderr, cli.FormatUsage(cmd, "<report.json>", generateOptionGroups(cmd)))
+				return fmt.Errorf("Requires exactly 1 report")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntP("failure", "f", 1, "number of the failure in the report to promote (default: 1)")
+	cmd.Flags().String("id", "", "ID and file name of the entry (default: derived from the error)")
+	cmd.Flags().String("title", "", "short description of the issue (default: the error message)")
+	cmd.Flags().String("pattern", "", "regex matched against error messages (default: the error with numbers as wildcards)")
+	cmd.Flags().String("kb-dir", "", "knowledge base directory (default: .sherlock/known-issues in the repository)")
+
+	cmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		option := cli.StripInvalidFlag(err)
+
+		fmt.Fprintf(os.Stderr, "unknown option: %s\n", option)
+		fmt.Fprint(os.Stderr, cli.FormatUsage(cmd, "<report.json>", generateOptionGroups(cmd)))
+		return nil
+	})
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		number, _ := cmd.Flags().GetInt("failure")
+		id, _ := cmd.Flags().GetString("id")
+		title, _ := cmd.Flags().GetString("title")
+		pattern, _ := cmd.Flags().GetString("pattern")
+		dir, _ := cmd.Flags().GetString("kb-dir")
+
+		rep, err := report.Load(args[0])
+		if err != nil {
+			logger.GlobalLogger.Errorf("Failed to read report: %v", err)
+			return err
+		}
+
+		if number < 1 || number > len(rep.Failures) {
+			logger.GlobalLogger.Errorf("Report has %d failure(s), cannot promote failure %d", len(rep.Failures), number)
+			return fmt.Errorf("invalid failure number: %d", number)
+		}
+		failure := rep.Failures[number-1]
+
+		// Batched reports keep each failure's analysis in a combined one
+		response := ai.ParseResponse(rep.AnalysisFor(failure))
+		if response.RootCause == "" {
+			logger.GlobalLogger.Errorf("Failure %d has no analysis with a root cause to promote", number)
+			return fmt.Errorf("no root cause in analysis")
+		}
+
+		errorLine := strings.TrimSpace(strings.Split(failure.Error, "\n")[0])
+		entry := kb.Entry{
+			ID:          id,
+			Title:       title,
+			Pattern:     pattern,
+			Explanation: response.RootCause,
+			Fixes:       response.Fixes,
+		}
+		if entry.ID == "" {
+			entry.ID = kb.Slug(errorLine)
+		}
+		if entry.Title == "" {
+			entry.Title = errorLine
+		}
+		if entry.Pattern == "" {
+			entry.Pattern = kb.PatternFromError(errorLine)
+		}
+
+		if dir == "" {
+			dir = defaultDir()
+		}
+
+		path, err := kb.Save(dir, entry)
+		if err != nil {
+			logger.GlobalLogger.Errorf("Failed to save known issue: %v", err)
+			return err
+		}
+
+		logger.GlobalLogger.Successf("Added known issue %s matching: %s", path, entry.Pattern)
+		return nil
+	}
+
+	return cmd
+}
+
+// Places the knowledge base at the repository root, or in the working
+// directory outside a repository
+func defaultDir() string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return kb.DefaultDir
+	}
+
+	if repo, err := git.OpenRepository(wd, 5); err == nil {
+		return filepath.Join(repo.Path(), kb.DefaultDir)
+	}
+
+	return filepath.Join(wd, kb.DefaultDir)
+}
+
+func generateOptionGroups(cmd *cobra.Command) []cli.FlagGroup {
+	groups := []cli.FlagGroup{
+		{
+			Name: "Knowledge base options",
+			Flags: []*pflag.Flag{
+				cmd.Flags().Lookup("failure"),
+				cmd.Flags().Lookup("id"),
+				cmd.Flags().Lookup("title"),
+				cmd.Flags().Lookup("pattern"),
+				cmd.Flags().Lookup("kb-dir"),
+			},
+		},
+	}
+
+	return groups
+}
@@ -5,6 +5,12 @@ import (
 	"os"
 
 	"github.com/anthonydip/sherlock/cmd/analyze"
+	"github.com/anthonydip/sherlock/cmd/bisect"
+	"github.com/anthonydip/sherlock/cmd/cache"
+	"github.com/anthonydip/sherlock/cmd/chat"
+	"github.com/anthonydip/sherlock/cmd/fix"
+	"github.com/anthonydip/sherlock/cmd/flaky"
+	"github.com/anthonydip/sherlock/cmd/kb"
 	"github.com/anthonydip/sherlock/internal/cli"
 	"github.com/anthonydip/sherlock/internal/logger"
 	"github.com/spf13/cobra"
@@ -71,6 +77,13 @@ func NewRootCmd(versionInfo VersionInfo) *cobra.Command {
 
 	rootCmd.AddCommand(
 		analyze.NewAnalyzeCmd(),
+		analyze.NewDiffCmd(),
+		bisect.NewBisectCmd(),
+		kb.NewKBCmd(),
+		cache.NewCacheCmd(),
+		flaky.NewFlakyCmd(),
+		chat.NewChatCmd(),
+		fix.NewFixCmd(),
 	)
 
 	return rootCmd
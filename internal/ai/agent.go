@@ -0,0 +1,78 @@
+package ai
+
+import (
+	"fmt"
+
+	"github.com/anthonydip/sherlock/internal/ai/message"
+	"github.com/anthonydip/sherlock/internal/logger"
+)
+
+// Default number of tool calling rounds before the AI must answer
+const DefaultMaxSteps = 6
+
+const agentInstructions = "You can call tools to read more of the repository (files, blame, history, search) " +
+	"when the context in the prompt is not enough to determine the root cause. " +
+	"Only request what you need, then answer in the format the prompt asks for."
+
+const budgetExhausted = "The tool budget is used up. Answer now with what you know, in the format the first message asks for."
+
+// Lets the AI request more code through tools before answering
+type Agent struct {
+	client   ToolClient
+	tools    Toolbox
+	maxSteps int
+}
+
+func NewAgent(client ToolClient, tools Toolbox, maxSteps int) *Agent {
+	if maxSteps <= 0 {
+		maxSteps = DefaultMaxSteps
+	}
+	return &Agent{client: client, tools: tools, maxSteps: maxSteps}
+}
+
+// Answers the prompt, running the tool calls the AI asks for until it
+// answers or the step budget runs out
+func (a *Agent) AnalyzeTestFailure(prompt string) (string, error) {
+	messages := []Message{
+		{Role: message.System, Content: agentInstructions},
+		{Role: message.User, Content: prompt},
+	}
+	specs := a.tools.Specs()
+
+	for step := 1; step <= a.maxSteps; step++ {
+		reply, err := a.client.ChatWithTools(messages, specs)
+		if err != nil {
+			return "", err
+		}
+		if len(reply.ToolCalls) == 0 {
+			return reply.Content, nil
+		}
+
+		messages = append(messages, reply)
+		for _, call := range reply.ToolCalls {
+			logger.GlobalLogger.Verbosef("AI tool call %d/%d: %s(%s)", step, a.maxSteps, call.Function.Name, call.Function.Arguments)
+
+			// Errors go back to the AI so it can correct the call
+			result, err := a.tools.Call(call.Function.Name, call.Function.Arguments)
+			if err != nil {
+				logger.GlobalLogger.Debugf("Tool call %s failed: %v", call.Function.Name, err)
+				result = fmt.Sprintf("error: %v", err)
+			}
+
+			messages = append(messages, Message{Role: message.Tool, ToolCallID: call.ID, Content: result})
+		}
+	}
+
+	logger.GlobalLogger.Verbosef("AI used all %d tool steps, requesting an answer", a.maxSteps)
+	messages = append(messages, Message{Role: message.User, Content: budgetExhausted})
+	reply, err := a.client.ChatWithTools(messages, nil)
+	if err != nil {
+		return "", err
+	}
+	return reply.Content, nil
+}
+
+// Follow-up conversations go to the provider without tools
+func (a *Agent) Chat(messages []Message) (string, error) {
+	return a.client.Chat(messages)
+}
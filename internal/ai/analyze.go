@@ -0,0 +1,44 @@
+package ai
+
+import (
+	"github.com/anthonydip/sherlock/internal/git"
+	"github.com/anthonydip/sherlock/internal/logger"
+	"github.com/anthonydip/sherlock/internal/parsers"
+)
+
+// Analyzes a failure from its prompt, or from the failure itself for clients
+// that do not read prompts
+func AnalyzeFailure(client AIClient, failure parsers.TestFailure) (string, error) {
+	if analyzer, ok := client.(FailureAnalyzer); ok {
+		return analyzer.AnalyzeFailure(failure)
+	}
+
+	prompt := GeneratePrompt(failure)
+	logger.GlobalLogger.Debugf("Generated prompt for %s:\n%s", failure.TestName, prompt)
+
+	return client.AnalyzeTestFailure(prompt)
+}
+
+// Analyzes several failures in one request
+func AnalyzeFailures(client AIClient, failures []parsers.TestFailure) (string, error) {
+	if analyzer, ok := client.(FailureAnalyzer); ok {
+		return analyzer.AnalyzeFailures(failures)
+	}
+
+	prompt := GenerateBatchPrompt(failures)
+	logger.GlobalLogger.Debugf("Generated prompt for failure(s):\n%s", prompt)
+
+	return client.AnalyzeTestFailure(prompt)
+}
+
+// Explains how the commit found by bisect broke the test
+func AnalyzeBisect(client AIClient, culprit git.CommitInfo, testCommand string, testOutput string) (string, error) {
+	if analyzer, ok := client.(FailureAnalyzer); ok {
+		return analyzer.AnalyzeBisect(culprit, testCommand, testOutput)
+	}
+
+	prompt := GenerateBisectPrompt(culprit, testCommand, testOutput)
+	logger.GlobalLogger.Debugf("Generated bisect prompt:\n%s", prompt)
+
+	return client.AnalyzeTestFailure(prompt)
+}
@@ -0,0 +1,184 @@
+package ai
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/anthonydip/sherlock/internal/logger"
+)
+
+// Version of the prompt templates, part of the cache key so responses to
+// older prompts are not reused after the templates change. Bump it with every
+// change to the prompt output.
+const PromptVersion = "3"
+
+// Default lifetime of cached responses
+const DefaultCacheTTL = 7 * 24 * time.Hour
+
+var blankLinesRegex = regexp.MustCompile(`\n{3,}`)
+
+// Serves repeated prompts from an on-disk cache instead of the provider
+type CachedClient struct {
+	client   AIClient
+	provider string
+	model    string
+	dir      string
+	ttl      time.Duration // Zero keeps responses forever
+
+	// Answers found with tools are kept apart from prompt-only ones
+	tools bool
+}
+
+type cacheEntry struct {
+	Provider  string    `json:"provider"`
+	Model     string    `json:"model"`
+	CreatedAt time.Time `json:"created_at"`
+	Response  string    `json:"response"`
+}
+
+func NewCachedClient(client AIClient, opts AIOptions) *CachedClient {
+	return &CachedClient{
+		client:   client,
+		provider: opts.Provider,
+		model:    opts.Model,
+		dir:      opts.CacheDir,
+		ttl:      opts.CacheTTL,
+		tools:    isAgent(client),
+	}
+}
+
+func isAgent(client AIClient) bool {
+	_, ok := client.(*Agent)
+	return ok
+}
+
+// Returns the directory of the response cache under the user cache directory
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "sherlock", "responses"), nil
+}
+
+func (c *CachedClient) AnalyzeTestFailure(prompt string) (string, error) {
+	path := filepath.Join(c.dir, c.fingerprint(prompt)+".json")
+
+	if response, ok := c.read(path); ok {
+		return response, nil
+	}
+
+	response, err := c.client.AnalyzeTestFailure(prompt)
+	if err != nil {
+		return "", err
+	}
+
+	c.write(path, response)
+	return response, nil
+}
+
+// Conversations are not repeated, so they always reach the provider
+func (c *CachedClient) Chat(messages []Message) (string, error) {
+	return c.client.Chat(messages)
+}
+
+// Identifies a prompt independently of insignificant whitespace differences
+func (c *CachedClient) fingerprint(prompt string) string {
+	normalized := strings.ReplaceAll(prompt, "\r\n", "\n")
+
+	lines := strings.Split(normalized, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	normalized = blankLinesRegex.ReplaceAllString(strings.TrimSpace(strings.Join(lines, "\n")), "\n\n")
+
+	key := []string{c.provider, c.model, PromptVersion, normalized}
+	if c.tools {
+		key = append(key, "tools")
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(key, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *CachedClient) read(path string) (string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		logger.GlobalLogger.Debugf("Ignoring corrupt cached response %s: %v", path, err)
+		return "", false
+	}
+
+	if c.ttl > 0 && time.Since(entry.CreatedAt) > c.ttl {
+		logger.GlobalLogger.Debugf("Cached response %s expired", filepath.Base(path))
+		os.Remove(path)
+		return "", false
+	}
+
+	logger.GlobalLogger.Verbosef("Using cached AI response from %s", entry.CreatedAt.Local().Format("2006-01-02 15:04"))
+	return entry.Response, true
+}
+
+// Cache write failures only cost a repeated request, so they are logged and ignored
+func (c *CachedClient) write(path string, response string) {
+	data, err := json.Marshal(cacheEntry{
+		Provider:  c.provider,
+		Model:     c.model,
+		CreatedAt: time.Now().UTC(),
+		Response:  response,
+	})
+	if err != nil {
+		logger.GlobalLogger.Debugf("Failed to encode cached response: %v", err)
+		return
+	}
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		logger.GlobalLogger.Debugf("Failed to create response cache: %v", err)
+		return
+	}
+
+	// Write atomically so concurrent runs never read partial entries
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		logger.GlobalLogger.Debugf("Failed to write cached response: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		logger.GlobalLogger.Debugf("Failed to write cached response: %v", err)
+		os.Remove(tmp)
+	}
+}
+
+// Removes all cached responses, returning how many were removed
+func ClearCache(dir string) (int, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	removed := 0
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, file.Name())); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+
+	return removed, nil
+}
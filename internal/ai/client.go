@@ -2,21 +2,50 @@ package ai
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/anthonydip/sherlock/internal/ai/groq"
+	"github.com/anthonydip/sherlock/internal/ai/heuristic"
+	"github.com/anthonydip/sherlock/internal/logger"
 )
 
 type AIOptions struct {
 	Provider string
 	Model    string
 	APIKey   string
+
+	// Response cache directory, empty to disable caching
+	CacheDir string
+	CacheTTL time.Duration
+
+	// Tools offered to providers that support function calling, nil to
+	// analyze from the prompt alone
+	Tools    Toolbox
+	MaxSteps int
 }
 
 func NewAIClient(opts AIOptions) (AIClient, error) {
+	var client AIClient
 	switch opts.Provider {
 	case "groq":
-		return groq.NewGroqClient(opts.APIKey, opts.Model), nil
+		client = groq.NewGroqClient(opts.APIKey, opts.Model)
+	case "heuristic":
+		// Offline analysis is instant, so there is nothing to cache
+		return heuristic.NewHeuristicClient(), nil
 	default:
 		return nil, fmt.Errorf("Unsupported AI client type: %s", opts.Provider)
 	}
+
+	if opts.Tools != nil {
+		if toolClient, ok := client.(ToolClient); ok {
+			client = NewAgent(toolClient, opts.Tools, opts.MaxSteps)
+		} else {
+			logger.GlobalLogger.Debugf("%s does not support tool calls, analyzing from the prompt alone", opts.Provider)
+		}
+	}
+
+	if opts.CacheDir != "" {
+		return NewCachedClient(client, opts), nil
+	}
+	return client, nil
 }
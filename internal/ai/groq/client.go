@@ -7,6 +7,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+
+	"github.com/anthonydip/sherlock/internal/ai/message"
 )
 
 type GroqClient struct {
@@ -28,21 +30,14 @@ func NewGroqClient(apiKey, model string) *GroqClient {
 }
 
 // Structs for http request messages and responses
-type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
-
 type RequestBody struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
+	Model    string             `json:"model"`
+	Messages []message.Message  `json:"messages"`
+	Tools    []message.ToolSpec `json:"tools,omitempty"`
 }
 
 type Choice struct {
-	Message struct {
-		Role    string `json:"role"`
-		Content string `json:"content"`
-	} `json:"message"`
+	Message message.Message `json:"message"`
 }
 
 type ResponseBody struct {
@@ -50,27 +45,41 @@ type ResponseBody struct {
 }
 
 func (c *GroqClient) AnalyzeTestFailure(prompt string) (string, error) {
+	return c.Chat([]message.Message{
+		{Role: message.User, Content: prompt},
+	})
+}
+
+func (c *GroqClient) Chat(messages []message.Message) (string, error) {
+	reply, err := c.ChatWithTools(messages, nil)
+	if err != nil {
+		return "", err
+	}
+	return reply.Content, nil
+}
+
+// Groq follows OpenAI's function calling API
+func (c *GroqClient) ChatWithTools(messages []message.Message, tools []message.ToolSpec) (message.Message, error) {
 	// Builds the GROQ url
 	groqURL := c.baseURL + "/chat/completions"
 
 	// Formats request body to match GROQ's API
 	requestBody := RequestBody{
-		Model: c.model,
-		Messages: []Message{
-			{Role: "user", Content: prompt},
-		},
+		Model:    c.model,
+		Messages: messages,
+		Tools:    tools,
 	}
 
 	// Converts the request body into a JSON byte array
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return "", err
+		return message.Message{}, err
 	}
 
 	// Builds the post request using GROQ's API and sets the headers
 	req, err := http.NewRequest("POST", groqURL, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", err
+		return message.Message{}, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer " + c.apiKey)
@@ -79,21 +88,21 @@ func (c *GroqClient) AnalyzeTestFailure(prompt string) (string, error) {
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return message.Message{}, err
 	}
 
 	// Reads the response body into a byte array
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return message.Message{}, err
 	}
 
 	// Parses and logs the response
 	var response ResponseBody
 	err = json.Unmarshal(body, &response)
 	if err != nil || len(response.Choices) == 0 {
-		return "", fmt.Errorf("Failed to parse response or no result: %s", string(body))
+		return message.Message{}, fmt.Errorf("Failed to parse response or no result: %s", string(body))
 	}
-	return response.Choices[0].Message.Content, nil
+	return response.Choices[0].Message, nil
 }
@@ -0,0 +1,164 @@
+package heuristic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/anthonydip/sherlock/internal/ai/message"
+	"github.com/anthonydip/sherlock/internal/git"
+	"github.com/anthonydip/sherlock/internal/parsers"
+)
+
+// Rule-based stand-in for an AI provider. It recognizes common classes of
+// failures from the parsed test failure and needs no network access.
+type HeuristicClient struct{}
+
+func NewHeuristicClient() *HeuristicClient {
+	return &HeuristicClient{}
+}
+
+// What the rules know about a failure
+type failureData struct {
+	testName string
+	errorMsg string
+	location string
+
+	// Error message and test output the rules are matched against
+	errorText string
+
+	// Most recent commit touching the failing code, if known
+	commit string
+
+	// Past intermittent failures, if the test is flaky
+	flaky string
+}
+
+func newFailureData(failure parsers.TestFailure) failureData {
+	data := failureData{
+		testName:  failure.TestName,
+		errorMsg:  strings.TrimSpace(strings.Split(strings.TrimSpace(failure.Error), "\n")[0]),
+		location:  failure.Location,
+		errorText: failure.Error,
+		flaky:     failure.Flaky,
+	}
+
+	if failure.FullMessage != "" {
+		data.errorText += "\n" + failure.FullMessage
+	}
+
+	if len(failure.RelatedCommits) > 0 {
+		commit := failure.RelatedCommits[0]
+		data.commit = fmt.Sprintf("%s by %s on %s: %s",
+			shortHash(commit.Hash),
+			commit.Author,
+			commit.Date.Format("2006-01-02"),
+			strings.Split(commit.Message, "\n")[0],
+		)
+	}
+
+	return data
+}
+
+func (c *HeuristicClient) AnalyzeFailure(failure parsers.TestFailure) (string, error) {
+	return formatAnalysis(newFailureData(failure)), nil
+}
+
+// Answers in the batch format, one section per failure
+func (c *HeuristicClient) AnalyzeFailures(failures []parsers.TestFailure) (string, error) {
+	var sb strings.Builder
+	for _, failure := range failures {
+		data := newFailureData(failure)
+		root, fixes := classify(data)
+
+		sb.WriteString(fmt.Sprintf("#### %s\n", data.testName))
+		sb.WriteString(fmt.Sprintf("**Root Cause**: %s\n", root))
+		sb.WriteString("**Quick Fix**:\n")
+		for _, fix := range fixes[:min(2, len(fixes))] {
+			sb.WriteString(fmt.Sprintf("- %s\n", fix))
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// Classifies the failing test's output at the commit found by bisect
+func (c *HeuristicClient) AnalyzeBisect(culprit git.CommitInfo, testCommand string, testOutput string) (string, error) {
+	return formatAnalysis(failureData{
+		errorText: testOutput,
+		commit:    fmt.Sprintf("%s: %s", shortHash(culprit.Hash), strings.Split(culprit.Message, "\n")[0]),
+	}), nil
+}
+
+// The rules need the parsed failure, which a prompt written for a language
+// model does not reliably carry, so a bare prompt gets the generic analysis
+func (c *HeuristicClient) AnalyzeTestFailure(prompt string) (string, error) {
+	return formatAnalysis(failureData{}), nil
+}
+
+// Rules cannot hold a conversation; the opening analysis comes from
+// AnalyzeFailure
+func (c *HeuristicClient) Chat(messages []message.Message) (string, error) {
+	return "The offline heuristic analyzer cannot answer follow-up questions. Start the chat with an AI provider (--ai-provider and --api-key) to discuss the failure.", nil
+}
+
+func formatAnalysis(data failureData) string {
+	root, fixes := classify(data)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("### Root Cause\n%s\n\n", root))
+	sb.WriteString("### Suggested Fixes\n")
+	for _, fix := range fixes {
+		sb.WriteString(fmt.Sprintf("- %s\n", fix))
+	}
+	sb.WriteString("\n_Generated offline by the heuristic analyzer; no AI provider was used._")
+
+	return sb.String()
+}
+
+func shortHash(hash string) string {
+	return hash[:min(7, len(hash))]
+}
+
+// Matches the failure against the rules, adding what is known about where it happened
+func classify(failure failureData) (string, []string) {
+	root, fixes := "", []string(nil)
+
+	for _, rule := range rules {
+		if match := rule.pattern.FindStringSubmatch(failure.errorText); match != nil {
+			root, fixes = rule.explain(match)
+			root = fmt.Sprintf("Classified as **%s**. %s", rule.name, root)
+			break
+		}
+	}
+
+	if root == "" {
+		root = "The failure does not match a known pattern, so no specific cause could be determined offline."
+		if failure.errorMsg != "" {
+			root += fmt.Sprintf(" The test reported: `%s`.", failure.errorMsg)
+		}
+		fixes = []string{
+			"Read the full error message and stack trace for the first frame in project code",
+			"Re-run the analysis with an AI provider for a detailed explanation",
+		}
+	}
+
+	if failure.location != "" {
+		root += fmt.Sprintf(" The failure surfaced at `%s`.", failure.location)
+	}
+
+	// A flaky test is more likely failing intermittently than broken by a change
+	if failure.flaky != "" {
+		root = fmt.Sprintf("This test is flaky (%s), so the failure is likely intermittent rather than caused by a code change. %s", failure.flaky, root)
+		fixes = append([]string{
+			"Look for timing, ordering or shared-state dependencies in the test before changing the code under test",
+			"Re-run the test to confirm whether the failure reproduces",
+		}, fixes...)
+		return root, fixes
+	}
+
+	if failure.commit != "" {
+		fixes = append(fixes, fmt.Sprintf("Start with the most recent change to the failing code: %s", failure.commit))
+	}
+
+	return root, fixes
+}
@@ -0,0 +1,130 @@
+package heuristic
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// A known class of failure, recognized from the error text
+type rule struct {
+	name    string
+	pattern *regexp.Regexp
+
+	// Builds the root cause and fixes from the pattern's submatches
+	explain func(match []string) (string, []string)
+}
+
+// Rules are tried in order, so more specific failures come first
+var rules = []rule{
+	{
+		name:    "snapshot mismatch",
+		pattern: regexp.MustCompile(`(?i)(?:toMatch(?:Inline)?Snapshot|snapshot .*(?:does not match|mismatched|obsolete)|Snapshot name: ` + "`" + `([^` + "`" + `]+)` + "`" + `)`),
+		explain: func(match []string) (string, []string) {
+			return "The rendered output no longer matches the stored snapshot. Either the change to the output is intended and the snapshot is stale, or the component or serializer regressed.",
+				[]string{
+					"Review the snapshot diff in the test output to decide whether the new output is correct",
+					"If the change is intended, update the snapshot (e.g. `jest -u`) and commit it with the code change",
+					"If it is not, check recent changes to the rendered component, its props or the snapshot serializer",
+				}
+		},
+	},
+	{
+		name:    "module not found",
+		pattern: regexp.MustCompile(`(?:Cannot find module '([^']+)'|Module not found: .*?'([^']+)'|No module named '([^']+)'|ERR_MODULE_NOT_FOUND|cannot find package "([^"]+)")`),
+		explain: func(match []string) (string, []string) {
+			module := firstNonEmpty(match[1:]...)
+			if module == "" {
+				module = "a required module"
+			} else {
+				module = fmt.Sprintf("`%s`", module)
+			}
+			return fmt.Sprintf("The test could not load %s. The import path is wrong, the file was moved or renamed, or the dependency is not installed in this environment.", module),
+				[]string{
+					fmt.Sprintf("Check that %s exists at the imported path, accounting for recent renames", module),
+					"If it is a package, make sure it is listed in the dependencies and installed (e.g. `npm ci`, `pip install -r requirements.txt`)",
+					"Verify module resolution settings such as path aliases, `moduleNameMapper` or `PYTHONPATH`",
+				}
+		},
+	},
+	{
+		name:    "network refused",
+		pattern: regexp.MustCompile(`(?i)(?:ECONNREFUSED\s*([\w.\-\[\]:]+)?|connection refused|ConnectionRefusedError)`),
+		explain: func(match []string) (string, []string) {
+			target := "a required service"
+			if len(match) > 1 && match[1] != "" {
+				target = fmt.Sprintf("`%s`", match[1])
+			}
+			return fmt.Sprintf("The test tried to connect to %s but nothing was listening. This is usually an environment problem rather than a code bug: the service (database, API, cache) was not started or is on a different host or port.", target),
+				[]string{
+					"Start the required service before the tests run (e.g. a CI service container or `docker compose up`)",
+					"Check the configured host and port against the environment, including environment variables in CI",
+					"Mock the network dependency in unit tests so they do not require a live service",
+				}
+		},
+	},
+	{
+		name:    "undefined property",
+		pattern: regexp.MustCompile(`(?:Cannot read propert(?:y|ies) of (undefined|null)(?: \(reading '([^']+)'\))?|Cannot read property '([^']+)' of (undefined|null)|undefined is not an object \(evaluating '([^']+)'\)|'NoneType' object has no attribute '([^']+)')`),
+		explain: func(match []string) (string, []string) {
+			property := firstNonEmpty(match[2], match[3], match[5], match[6])
+			value := firstNonEmpty(match[1], match[4])
+			if value == "" {
+				value = "undefined"
+			}
+
+			cause := fmt.Sprintf("The code accessed a property on a value that was %s.", value)
+			if property != "" {
+				cause = fmt.Sprintf("The code read `%s` from a value that was %s.", property, value)
+			}
+			return cause + " An object the code expects is missing, typically because a lookup, mock or API response returned nothing or has a different shape than before.",
+				[]string{
+					"Check where the value comes from on the failing line and why it is empty in this test",
+					"If the value can legitimately be missing, guard the access (e.g. optional chaining or an explicit check)",
+					"If it should always exist, fix the mock, fixture or data source that stopped providing it",
+				}
+		},
+	},
+	{
+		name:    "assertion mismatch",
+		pattern: regexp.MustCompile(`(?s)(?:expect\(.*?\)\.(\w+)|AssertionError|assert .*==|Expected[:\s].*?Received[:\s])`),
+		explain: func(match []string) (string, []string) {
+			matcher := ""
+			if len(match) > 1 && match[1] != "" {
+				matcher = fmt.Sprintf(" (`%s`)", match[1])
+			}
+			return fmt.Sprintf("An assertion%s failed: the value produced by the code under test differs from what the test expects. Either the code's behavior changed or the expectation is out of date.", matcher),
+				[]string{
+					"Compare the expected and received values in the test output to see what changed",
+					"Check the recent changes to the code under test for an unintended behavior change",
+					"If the new behavior is correct, update the test's expectation",
+				}
+		},
+	},
+	// Stack frames name timer functions (setTimeout, listOnTimeout), so the
+	// pattern only matches whole words and runs after the specific errors
+	{
+		name:    "timeout",
+		pattern: regexp.MustCompile(`(?i)(?:Exceeded timeout of (\d+)\s*ms|\btimed? ?out\b|\bTimeoutError\b|\bETIMEDOUT\b|\bdeadline exceeded\b)`),
+		explain: func(match []string) (string, []string) {
+			limit := ""
+			if len(match) > 1 && match[1] != "" {
+				limit = fmt.Sprintf(" of %sms", match[1])
+			}
+			return fmt.Sprintf("The test did not finish within the timeout%s. A promise was never resolved, a callback was never called, or an operation is waiting on a slow or unavailable dependency.", limit),
+				[]string{
+					"Make sure every async path resolves or rejects, and that `done` callbacks are called on all branches",
+					"Look for missing `await`s, unmocked timers or network calls in the code under test",
+					"Only raise the timeout if the operation is legitimately slow",
+				}
+		},
+	},
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
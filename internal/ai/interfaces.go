@@ -1,5 +1,41 @@
 package ai
 
+import (
+	"github.com/anthonydip/sherlock/internal/ai/message"
+	"github.com/anthonydip/sherlock/internal/git"
+	"github.com/anthonydip/sherlock/internal/parsers"
+)
+
+type Message = message.Message
+
 type AIClient interface {
 	AnalyzeTestFailure(prompt string) (string, error)
+
+	// Continues a conversation, returning the assistant's reply
+	Chat(messages []Message) (string, error)
+}
+
+// Implemented by clients that work from the parsed failure data instead of
+// a prompt, such as the offline heuristic analyzer
+type FailureAnalyzer interface {
+	AnalyzeFailure(failure parsers.TestFailure) (string, error)
+	AnalyzeFailures(failures []parsers.TestFailure) (string, error)
+	AnalyzeBisect(culprit git.CommitInfo, testCommand string, testOutput string) (string, error)
+}
+
+// Implemented by clients of providers that support function calling
+type ToolClient interface {
+	AIClient
+
+	// Continues a conversation in which the assistant may call the given
+	// tools. The reply either answers or lists the calls to make.
+	ChatWithTools(messages []Message, tools []message.ToolSpec) (Message, error)
+}
+
+// Functions the AI can call to look at the project during an analysis
+type Toolbox interface {
+	Specs() []message.ToolSpec
+
+	// Runs a tool with its JSON arguments, returning the text shown to the AI
+	Call(name string, arguments string) (string, error)
 }
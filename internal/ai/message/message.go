@@ -0,0 +1,47 @@
+package message
+
+// Roles of the participants of a conversation
+const (
+	System    = "system"
+	User      = "user"
+	Assistant = "assistant"
+
+	// Result of a tool call requested by the assistant
+	Tool = "tool"
+)
+
+// Turn of a conversation with an AI provider. Kept in its own package so the
+// provider clients can use it without importing ai.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+
+	// Tools the assistant asked to call instead of answering
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+
+	// Call a tool message answers
+	ToolCallID string `json:"tool_call_id,omitempty"`
+}
+
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON object
+}
+
+// Function the assistant may call, described with a JSON schema
+type ToolSpec struct {
+	Type     string       `json:"type"`
+	Function FunctionSpec `json:"function"`
+}
+
+type FunctionSpec struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Parameters  map[string]any `json:"parameters"`
+}
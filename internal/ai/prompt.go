@@ -4,9 +4,22 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/anthonydip/sherlock/internal/git"
 	"github.com/anthonydip/sherlock/internal/parsers"
 )
 
+// Limits for the test output and diff included in bisect prompts
+const (
+	maxBisectOutput = 4000
+	maxBisectDiff   = 20000
+)
+
+// Limit for files added to a chat
+const maxChatFile = 20000
+
+// Limit for the test output sent back when a patch does not fix the tests
+const maxVerifyOutput = 4000
+
 func GeneratePrompt(failure parsers.TestFailure) string {
 	var sb strings.Builder
 
@@ -25,7 +38,14 @@ func GeneratePrompt(failure parsers.TestFailure) string {
 
 	sb.WriteString(fmt.Sprintf("\nFile: %s (Line %d)\n", failure.Location, failure.LineNumber))
 
-	if failure.Context.SurroundingCode != "" {
+	// The failure counts change every run and would defeat the response cache
+	if failure.Flaky != "" {
+		sb.WriteString("\nFlaky History: This test has passed and failed intermittently in past runs. Consider an intermittent cause such as timing, test order or shared state before attributing the failure to a code change.\n")
+	}
+
+	writeSuspectChanges(&sb, failure)
+
+	if failure.Context != nil && failure.Context.SurroundingCode != "" {
 		sb.WriteString(fmt.Sprintf("\nCode Context:\n%s\n", failure.Context.SurroundingCode))
 	}
 
@@ -33,9 +53,97 @@ func GeneratePrompt(failure parsers.TestFailure) string {
 		sb.WriteString(fmt.Sprintf("\nRecent Line Changes:\n%s\n", failure.CodeChanges))
 	}
 
+	writeRelatedCommits(&sb, failure.RelatedCommits)
+
+	writeStackFrames(&sb, failure)
+
 	return sb.String()
 }
 
+// Lists the commits that last modified the failing line, including the
+// file's previous paths when it was renamed
+func writeRelatedCommits(sb *strings.Builder, commits []git.CommitInfo) {
+	if len(commits) == 0 {
+		return
+	}
+
+	sb.WriteString("\nCommits That Last Modified This Line:\n")
+	for _, commit := range commits {
+		sb.WriteString(fmt.Sprintf("- %s by %s on %s: %s",
+			commit.Hash[:7],
+			commit.Author,
+			commit.Date.Format("2006-01-02"),
+			strings.Split(commit.Message, "\n")[0],
+		))
+		if commit.Path != "" {
+			sb.WriteString(fmt.Sprintf(" [%s", commit.Path))
+			if commit.PreviousPath != "" {
+				sb.WriteString(fmt.Sprintf(", renamed from %s", commit.PreviousPath))
+			}
+			sb.WriteString("]")
+		}
+		sb.WriteString("\n")
+	}
+}
+
+// Lists the changed hunks of files on the stack trace as the primary suspects
+func writeSuspectChanges(sb *strings.Builder, failure parsers.TestFailure) {
+	if len(failure.SuspectChanges) == 0 {
+		return
+	}
+
+	sb.WriteString("\nChanges To Files In The Stack Trace (primary suspects, uncommitted first):\n")
+	for _, suspect := range failure.SuspectChanges {
+		if suspect.Uncommitted {
+			sb.WriteString(fmt.Sprintf("File: %s (uncommitted)\n", suspect.Diff.Path))
+		} else {
+			sb.WriteString(fmt.Sprintf("File: %s (changed on branch)\n", suspect.Diff.Path))
+		}
+		for _, hunk := range suspect.Diff.Hunks {
+			for _, line := range suspect.Lines {
+				if hunk.ContainsLine(line) {
+					sb.WriteString(fmt.Sprintf("(contains stack trace line %d)\n", line))
+					break
+				}
+			}
+			sb.WriteString(hunk.String())
+		}
+	}
+}
+
+// Lists the project stack frames, highlighting frames in the source under test
+func writeStackFrames(sb *strings.Builder, failure parsers.TestFailure) {
+	if len(failure.StackFrames) == 0 {
+		return
+	}
+
+	sb.WriteString("\nStack Trace (project frames, most recent call first):\n")
+	for i, frame := range failure.StackFrames {
+		sb.WriteString(fmt.Sprintf("  %d. %s", i+1, frame.Location()))
+		if frame.Function != "" {
+			sb.WriteString(fmt.Sprintf(" in %s", frame.Function))
+		}
+		if !frame.IsTestFile() {
+			sb.WriteString("  <-- SOURCE UNDER TEST")
+		}
+		sb.WriteString("\n")
+	}
+
+	for _, frame := range failure.StackFrames {
+		// The failure location is already covered above
+		if frame.Location() == failure.Location || frame.IsTestFile() || frame.SurroundingCode == "" {
+			continue
+		}
+
+		sb.WriteString(fmt.Sprintf("\n>>> Source Under Test: %s (Line %d)\n", frame.File, frame.LineNumber))
+		sb.WriteString(fmt.Sprintf("Code Context:\n%s\n", frame.SurroundingCode))
+
+		if frame.CodeChanges != "" {
+			sb.WriteString(fmt.Sprintf("Recent Line Changes:\n%s\n", frame.CodeChanges))
+		}
+	}
+}
+
 func GenerateBatchPrompt(failures []parsers.TestFailure) string {
 	var sb strings.Builder
 
@@ -52,11 +160,112 @@ func GenerateBatchPrompt(failures []parsers.TestFailure) string {
 		sb.WriteString(fmt.Sprintf("Error: %s\n", failure.Error))
 		sb.WriteString(fmt.Sprintf("Location: %s:%d\n", failure.Location, failure.LineNumber))
 
-		if failure.Context.SurroundingCode != "" {
+		if failure.Flaky != "" {
+			sb.WriteString("Flaky History: This test has passed and failed intermittently in past runs\n")
+		}
+
+		if len(failure.SuspectChanges) > 0 {
+			var paths []string
+			for _, suspect := range failure.SuspectChanges {
+				paths = append(paths, suspect.Diff.Path)
+			}
+			sb.WriteString(fmt.Sprintf("Changed On Branch: %s\n", strings.Join(paths, ", ")))
+		}
+
+		if failure.Context != nil && failure.Context.SurroundingCode != "" {
 			sb.WriteString(fmt.Sprintf("Code Context:\n%s\n", failure.Context.SurroundingCode))
 		}
+
+		// Include the first frame in the source under test, if it differs from the location
+		for _, frame := range failure.StackFrames {
+			if frame.IsTestFile() || frame.Location() == failure.Location || frame.SurroundingCode == "" {
+				continue
+			}
+			sb.WriteString(fmt.Sprintf("Source Under Test: %s:%d\n%s\n", frame.File, frame.LineNumber, frame.SurroundingCode))
+			break
+		}
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+func GenerateBisectPrompt(culprit git.CommitInfo, testCommand string, testOutput string) string {
+	var sb strings.Builder
+
+	sb.WriteString("As a senior engineer, explain how this commit broke the test and respond EXACTLY in this format:\n\n")
+	sb.WriteString("### Root Cause\n[1-3 sentence explanation referencing the commit's changes]\n\n")
+	sb.WriteString("### Suggested Fixes\n- [Fix 1]\n- [Fix 2]\n\n")
+	sb.WriteString("### Code Example\n```[language]\n[Relevant code snippet]\n```\n\n")
+	sb.WriteString("---\n")
+
+	sb.WriteString("Git bisect identified this commit as the first one where the test fails. ")
+	sb.WriteString("Treat its changes as the definitive cause of the failure.\n\n")
+
+	sb.WriteString(fmt.Sprintf("Test Command: %s\n", testCommand))
+	sb.WriteString(fmt.Sprintf("\nTest Output (first bad commit):\n%s\n", truncateTail(testOutput, maxBisectOutput)))
+
+	sb.WriteString(fmt.Sprintf("\nCommit: %s\n", culprit.Hash))
+	sb.WriteString(fmt.Sprintf("Author: %s\n", culprit.Author))
+	sb.WriteString(fmt.Sprintf("Date: %s\n", culprit.Date.Format("2006-01-02 15:04:05")))
+	sb.WriteString(fmt.Sprintf("Message: %s\n", culprit.Message))
+
+	diff := culprit.Diff
+	if len(diff) > maxBisectDiff {
+		diff = diff[:maxBisectDiff] + "\n... (diff truncated)"
+	}
+	sb.WriteString(fmt.Sprintf("\nCommit Diff:\n%s\n", diff))
+
+	return sb.String()
+}
+
+// Follows an analysis with a request for a fix as a patch against the files
+func GeneratePatchPrompt(files []string) string {
+	var sb strings.Builder
+
+	sb.WriteString("Propose a minimal code change that fixes the root cause you identified, as a unified diff in git format ")
+	sb.WriteString("(--- a/path and +++ b/path headers with paths relative to the repository root, @@ hunk headers and exact context lines). ")
+	sb.WriteString("The diff must apply to the files below as they are. ")
+	sb.WriteString("Respond with only the diff in a ```diff block, or with NO PATCH if the failure cannot be fixed in these files.\n\n")
+
+	for _, file := range files {
+		sb.WriteString(file)
 		sb.WriteString("\n")
 	}
 
 	return sb.String()
 }
+
+// Asks for a corrected patch after the previous one failed to apply
+func GeneratePatchRetryPrompt(applyError string) string {
+	return fmt.Sprintf("The patch does not apply: %s\nRespond with a corrected diff that applies to the files exactly as shown, in a ```diff block.", applyError)
+}
+
+// Sends back the output of the failing tests rerun with the patch applied
+func GenerateVerifyRetryPrompt(testCommand string, testOutput string) string {
+	var sb strings.Builder
+
+	sb.WriteString("The tests still fail with the patch applied.\n\n")
+	sb.WriteString(fmt.Sprintf("Test Command: %s\n", testCommand))
+	sb.WriteString(fmt.Sprintf("\nTest Output:\n%s\n\n", truncateTail(testOutput, maxVerifyOutput)))
+	sb.WriteString("Respond with a corrected diff against the original files (not on top of the previous patch), in a ```diff block, ")
+	sb.WriteString("or with NO PATCH if the failure cannot be fixed in these files.")
+
+	return sb.String()
+}
+
+// Shares a file with the AI during a chat about a failure
+func GenerateFilePrompt(path string, content string) string {
+	if len(content) > maxChatFile {
+		content = content[:maxChatFile] + "\n... (file truncated)"
+	}
+	return fmt.Sprintf("Additional File: %s\n```\n%s\n```\n", path, strings.TrimRight(content, "\n"))
+}
+
+// Keeps the end of long output, where test runners report failures
+func truncateTail(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	return "... (output truncated)\n" + s[len(s)-limit:]
+}
@@ -0,0 +1,66 @@
+package ai
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Sections of an analysis written in the format requested by the prompts
+type Response struct {
+	RootCause string
+	Fixes     []string
+}
+
+var (
+	// Headings of single ("### Root Cause") and batch ("**Root Cause**:") responses
+	rootCauseRegex = regexp.MustCompile(`(?s)(?:###\s*Root Cause\s*\n|\*\*Root Cause\*\*:?)(.*?)(?:\n\s*###|\n\s*\*\*|$)`)
+	fixesRegex     = regexp.MustCompile(`(?s)(?:###\s*Suggested Fixes\s*\n|\*\*Quick Fix\*\*:?)(.*?)(?:\n\s*###|\n\s*\*\*|\n\s*_|$)`)
+	bulletRegex    = regexp.MustCompile(`(?m)^\s*(?:[-*]|\d+\.)\s+(.+)$`)
+
+	// Fenced diff of a patch response
+	patchBlockRegex = regexp.MustCompile("(?s)```(?:diff|patch)?[ \t]*\n(.*?)\n```")
+)
+
+// Extracts the root cause and fixes from an analysis
+func ParseResponse(text string) Response {
+	var response Response
+
+	if match := rootCauseRegex.FindStringSubmatch(text); match != nil {
+		response.RootCause = strings.TrimSpace(match[1])
+	}
+
+	if match := fixesRegex.FindStringSubmatch(text); match != nil {
+		for _, bullet := range bulletRegex.FindAllStringSubmatch(match[1], -1) {
+			response.Fixes = append(response.Fixes, strings.TrimSpace(bullet[1]))
+		}
+	}
+
+	return response
+}
+
+// Extracts the unified diff from a patch response, empty when the response
+// holds none
+func ExtractPatch(text string) string {
+	patch := ""
+	for _, match := range patchBlockRegex.FindAllStringSubmatch(text, -1) {
+		if isDiff(match[1]) {
+			patch = match[1]
+			break
+		}
+	}
+
+	// Some models answer with the bare diff
+	if patch == "" && isDiff(text) {
+		patch = strings.TrimSpace(text)
+	}
+	if patch == "" {
+		return ""
+	}
+
+	return strings.TrimRight(patch, "\n") + "\n"
+}
+
+func isDiff(text string) bool {
+	text = strings.TrimSpace(text)
+	return (strings.HasPrefix(text, "diff --git ") || strings.HasPrefix(text, "--- ")) && strings.Contains(text, "\n@@ ")
+}
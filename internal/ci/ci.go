@@ -0,0 +1,132 @@
+package ci
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/anthonydip/sherlock/internal/ai"
+	"github.com/anthonydip/sherlock/internal/report"
+)
+
+// CI systems annotations can be written for
+const (
+	Auto   = "auto"
+	None   = "none"
+	GitHub = "github"
+	GitLab = "gitlab"
+)
+
+var Providers = []string{Auto, None, GitHub, GitLab}
+
+// Root causes are cut at the first sentence past the minimum length, and
+// never shown longer than the maximum
+const (
+	minMessageLength = 80
+	maxMessageLength = 500
+)
+
+var (
+	markupRegex   = regexp.MustCompile("\\*\\*|__|`")
+	sentenceRegex = regexp.MustCompile(`[.!?](?:\s|$)`)
+)
+
+// Finding attached to a line of code in the CI interface
+type Annotation struct {
+	Path    string
+	Line    int
+	Title   string
+	Message string
+
+	// Intermittent failures are reported as warnings
+	Warning bool
+}
+
+// Detects the CI system from the environment variables it sets
+func Detect() string {
+	switch {
+	case os.Getenv("GITHUB_ACTIONS") == "true":
+		return GitHub
+	case os.Getenv("GITLAB_CI") == "true":
+		return GitLab
+	default:
+		return None
+	}
+}
+
+// Returns the branch the CI job was triggered for, empty outside CI. Pull
+// request jobs report their source branch.
+func Branch() string {
+	for _, variable := range []string{
+		"GITHUB_HEAD_REF", "GITHUB_REF_NAME",
+		"CI_MERGE_REQUEST_SOURCE_BRANCH_NAME", "CI_COMMIT_BRANCH",
+	} {
+		if branch := os.Getenv(variable); branch != "" {
+			return branch
+		}
+	}
+	return ""
+}
+
+// Validates a provider setting, resolving auto from the environment
+func Resolve(provider string) (string, error) {
+	switch provider {
+	case Auto:
+		return Detect(), nil
+	case None, GitHub, GitLab:
+		return provider, nil
+	default:
+		return "", fmt.Errorf("unknown annotation target '%s' (supported: %s)", provider, strings.Join(Providers, ", "))
+	}
+}
+
+// Builds one annotation per failure with its condensed root cause
+func Annotations(rep *report.Report) []Annotation {
+	var annotations []Annotation
+
+	for _, failure := range rep.Failures {
+		message := Condense(ai.ParseResponse(rep.AnalysisFor(failure)).RootCause)
+		if message == "" {
+			message = strings.TrimSpace(strings.Split(strings.TrimSpace(failure.Error), "\n")[0])
+		}
+		if failure.Flaky != "" {
+			message = fmt.Sprintf("Flaky test (%s). %s", failure.Flaky, message)
+		}
+
+		annotations = append(annotations, Annotation{
+			Path:    rep.RelativePath(failure),
+			Line:    failure.LineNumber,
+			Title:   failure.TestName,
+			Message: message,
+			Warning: failure.Flaky != "",
+		})
+	}
+
+	return annotations
+}
+
+// Shortens an analysis to its leading sentences in plain text
+func Condense(text string) string {
+	text = strings.Join(strings.Fields(markupRegex.ReplaceAllString(text, "")), " ")
+
+	for _, end := range sentenceRegex.FindAllStringIndex(text, -1) {
+		if end[0]+1 >= minMessageLength {
+			text = text[:end[0]+1]
+			break
+		}
+	}
+	if len(text) > maxMessageLength {
+		text = strings.TrimSpace(text[:maxMessageLength-3]) + "..."
+	}
+
+	return text
+}
+
+// Identifies a failure across pipelines so CI can track when it is resolved
+func fingerprint(annotation Annotation) string {
+	sum := sha1.Sum([]byte(annotation.Path + "\x00" + annotation.Title))
+	return hex.EncodeToString(sum[:])
+}
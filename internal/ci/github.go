@@ -0,0 +1,56 @@
+package ci
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var (
+	// Escaping of workflow command values and properties
+	dataEscaper     = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	propertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+)
+
+// Writes GitHub Actions workflow commands that annotate the failing lines
+func WriteGitHubAnnotations(w io.Writer, annotations []Annotation) {
+	for _, annotation := range annotations {
+		command := "error"
+		if annotation.Warning {
+			command = "warning"
+		}
+
+		var properties []string
+		if annotation.Path != "" {
+			properties = append(properties, "file="+propertyEscaper.Replace(annotation.Path))
+			if annotation.Line > 0 {
+				properties = append(properties, fmt.Sprintf("line=%d", annotation.Line))
+			}
+		}
+		properties = append(properties, "title="+propertyEscaper.Replace(annotation.Title))
+
+		fmt.Fprintf(w, "::%s %s::%s\n", command, strings.Join(properties, ","), dataEscaper.Replace(annotation.Message))
+	}
+}
+
+// Appends markdown to the job summary when running in GitHub Actions.
+// Returns false when no summary file is available.
+func WriteStepSummary(markdown string) (bool, error) {
+	path := os.Getenv("GITHUB_STEP_SUMMARY")
+	if path == "" {
+		return false, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := fmt.Fprintf(file, "%s\n", markdown); err != nil {
+		file.Close()
+		return false, err
+	}
+
+	return true, file.Close()
+}
@@ -0,0 +1,64 @@
+package ci
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+)
+
+// Default file name of the code quality report, declared in the job as
+// artifacts:reports:codequality
+const DefaultCodeQualityFile = "gl-code-quality-report.json"
+
+// Issue in GitLab's code quality report format
+type codeQualityIssue struct {
+	Description string              `json:"description"`
+	CheckName   string              `json:"check_name"`
+	Fingerprint string              `json:"fingerprint"`
+	Severity    string              `json:"severity"`
+	Location    codeQualityLocation `json:"location"`
+}
+
+type codeQualityLocation struct {
+	Path  string           `json:"path"`
+	Lines codeQualityLines `json:"lines"`
+}
+
+type codeQualityLines struct {
+	Begin int `json:"begin"`
+}
+
+// Writes the annotations as a GitLab code quality report
+func WriteCodeQuality(path string, annotations []Annotation) error {
+	issues := []codeQualityIssue{}
+	for _, annotation := range annotations {
+		severity := "major"
+		if annotation.Warning {
+			severity = "minor"
+		}
+
+		// GitLab requires a line, so file-level failures point at the first one
+		line := max(annotation.Line, 1)
+
+		issues = append(issues, codeQualityIssue{
+			Description: annotation.Title + ": " + annotation.Message,
+			CheckName:   "sherlock-test-failure",
+			Fingerprint: fingerprint(annotation),
+			Severity:    severity,
+			Location: codeQualityLocation{
+				Path:  annotation.Path,
+				Lines: codeQualityLines{Begin: line},
+			},
+		})
+	}
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(issues); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, buffer.Bytes(), 0644)
+}
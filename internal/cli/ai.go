@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/anthonydip/sherlock/internal/ai"
+	"github.com/anthonydip/sherlock/internal/logger"
+	"github.com/spf13/cobra"
+)
+
+// Registers the flags used to configure the AI provider
+func AddAIFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("api-key", "k", "", "AI API key override (openai|groq)")
+	cmd.Flags().StringP("model", "m", "", "ai model to use (default: gpt-3.5-turbo|llama3-70b-8192)")
+	cmd.Flags().String("ai-provider", "", "ai provider to use (openai|groq|heuristic, default: heuristic without an API key)")
+	cmd.Flags().Bool("no-cache", false, "always request a new AI response instead of reusing cached ones")
+	cmd.Flags().Duration("cache-ttl", ai.DefaultCacheTTL, "maximum age of reused AI responses, 0 to keep forever (default: 168h)")
+}
+
+func GetAIOptions(cmd *cobra.Command) (ai.AIOptions, error) {
+	opts := ai.AIOptions{
+		Provider: cmd.Flag("ai-provider").Value.String(),
+		Model:    cmd.Flag("model").Value.String(),
+	}
+
+	// The offline heuristic analyzer needs no key
+	if opts.Provider == "heuristic" {
+		opts.Model = getDefaultModel(opts.Provider)
+		return opts, nil
+	}
+
+	// Get API key (flag takes precedence over env vars)
+	apiKey, err := getAPIKey(cmd, opts.Provider)
+	if err != nil {
+		if opts.Provider != "" {
+			return ai.AIOptions{}, err
+		}
+
+		// Fall back to offline analysis when no provider is configured at all
+		logger.GlobalLogger.Warnf("No API key provided, using the offline heuristic analyzer (set --api-key for AI analysis)")
+		return ai.AIOptions{Provider: "heuristic", Model: getDefaultModel("heuristic")}, nil
+	}
+	opts.APIKey = apiKey
+
+	// Auto-detect provider if not specified
+	if opts.Provider == "" {
+		opts.Provider, err = detectProviderFromKey(opts.APIKey)
+		if err != nil {
+			return ai.AIOptions{}, err
+		}
+	} else {
+		// Check for invalid provider provided
+		if opts.Provider != "groq" && opts.Provider != "openai" && opts.Provider != "heuristic" {
+			return ai.AIOptions{}, fmt.Errorf("Invalid ai provider: %s", opts.Provider)
+		}
+	}
+
+	// Set default model if not specified
+	if opts.Model == "" {
+		opts.Model = getDefaultModel(opts.Provider)
+	}
+
+	setCacheOptions(cmd, &opts)
+
+	return opts, nil
+}
+
+func setCacheOptions(cmd *cobra.Command, opts *ai.AIOptions) {
+	if noCache, _ := cmd.Flags().GetBool("no-cache"); noCache {
+		logger.GlobalLogger.Verbosef("--no-cache used, not reusing AI responses")
+		return
+	}
+
+	dir, err := ai.DefaultCacheDir()
+	if err != nil {
+		logger.GlobalLogger.Debugf("Response cache disabled: %v", err)
+		return
+	}
+
+	opts.CacheDir = dir
+	opts.CacheTTL, _ = cmd.Flags().GetDuration("cache-ttl")
+}
+
+func getAPIKey(cmd *cobra.Command, provider string) (string, error) {
+	if key := cmd.Flag("api-key").Value.String(); key != "" {
+		return key, nil
+	}
+
+	switch provider {
+	case "groq":
+		return os.Getenv("GROQ_API_KEY"), nil
+	case "openai":
+		return os.Getenv("OPENAI_API_KEY"), nil
+	default:
+		if key := os.Getenv("GROQ_API_KEY"); key != "" {
+			return key, nil
+		}
+		if key := os.Getenv("OPENAI_API_KEY"); key != "" {
+			return key, nil
+		}
+	}
+
+	return "", fmt.Errorf("No API key provided (use --api-key or set %s_API_KEY)", strings.ToUpper(provider))
+}
+
+func detectProviderFromKey(key string) (string, error) {
+	switch {
+	case strings.HasPrefix(key, "gsk_"):
+		return "groq", nil
+	case strings.HasPrefix(key, "sk-"):
+		return "openai", nil
+	default:
+		return "", fmt.Errorf("Unable to detect provider from key format")
+	}
+}
+
+func getDefaultModel(provider string) string {
+	switch provider {
+	case "groq":
+		return "llama3-70b-8192"
+	case "openai":
+		return "gpt-3.5-turbo"
+	case "heuristic":
+		return "rules"
+	default:
+		return "llama3-70b-8192" // Fallback to Groq free model
+	}
+}
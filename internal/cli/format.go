@@ -88,10 +88,15 @@ func FormatRootUsage(cmd *cobra.Command) string {
 }
 
 func FormatSubcommandUsage(cmd *cobra.Command, groups []FlagGroup) string {
+	return FormatUsage(cmd, "<test-output>", groups)
+}
+
+// Formats subcommand usage with the given positional arguments
+func FormatUsage(cmd *cobra.Command, args string, groups []FlagGroup) string {
 	var builder strings.Builder
 
 	// Usage line
-	builder.WriteString(fmt.Sprintf("usage: %s [<options>] <test-output>\n\n", cmd.CommandPath()))
+	builder.WriteString(fmt.Sprintf("usage: %s [<options>] %s\n\n", cmd.CommandPath(), args))
 
 	// Flag groups
 	for _, group := range groups {
@@ -0,0 +1,95 @@
+package cluster
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/anthonydip/sherlock/internal/parsers"
+)
+
+// Failures sharing a signature, likely with the same root cause
+type Cluster struct {
+	Signature string
+	Indexes   []int // Positions of the failures in the input, in order
+}
+
+var (
+	errorTypeRegex = regexp.MustCompile(`^([A-Z][\w.]*(?:Error|Exception)|AssertionError|Error)\b`)
+
+	// Variable parts of error messages, most specific first
+	maskRegexes = []struct {
+		regex       *regexp.Regexp
+		replacement string
+	}{
+		{regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`), "<uuid>"},
+		{regexp.MustCompile(`\b0x[0-9a-fA-F]+\b`), "<hex>"},
+		{regexp.MustCompile(`\b[0-9a-f]{7,40}\b`), "<id>"},
+		{regexp.MustCompile(`\d+(?:\.\d+)?`), "<n>"},
+	}
+	whitespaceRegex = regexp.MustCompile(`\s+`)
+)
+
+// Builds the signature of a failure from its error type, its message with
+// numbers and ids masked, and the top frame in the source under test
+func Signature(failure parsers.TestFailure) string {
+	return fmt.Sprintf("%s | %s", ErrorSignature(failure), topFrame(failure))
+}
+
+// Builds the part of the signature describing the error alone, which stays
+// the same when code around the failure moves
+func ErrorSignature(failure parsers.TestFailure) string {
+	message := strings.TrimSpace(strings.Split(strings.TrimSpace(failure.Error), "\n")[0])
+
+	errorType := "Error"
+	if match := errorTypeRegex.FindStringSubmatch(message); match != nil {
+		errorType = match[1]
+	}
+
+	for _, mask := range maskRegexes {
+		message = mask.regex.ReplaceAllString(message, mask.replacement)
+	}
+	message = whitespaceRegex.ReplaceAllString(message, " ")
+
+	return fmt.Sprintf("%s | %s", errorType, message)
+}
+
+// Returns the first frame outside the tests, where a shared helper would
+// appear, falling back to the failure location
+func topFrame(failure parsers.TestFailure) string {
+	for _, frame := range failure.StackFrames {
+		if !frame.IsTestFile() {
+			return frame.Location()
+		}
+	}
+	return failure.Location
+}
+
+// Groups failures by signature, keeping the order in which clusters first appear
+func Group(failures []parsers.TestFailure) []Cluster {
+	var clusters []Cluster
+	index := make(map[string]int)
+
+	for i, failure := range failures {
+		signature := Signature(failure)
+
+		if c, ok := index[signature]; ok {
+			clusters[c].Indexes = append(clusters[c].Indexes, i)
+			continue
+		}
+
+		index[signature] = len(clusters)
+		clusters = append(clusters, Cluster{Signature: signature, Indexes: []int{i}})
+	}
+
+	return clusters
+}
+
+// Puts every failure in its own cluster, for when clustering is disabled
+func Single(failures []parsers.TestFailure) []Cluster {
+	clusters := make([]Cluster, len(failures))
+	for i, failure := range failures {
+		clusters[i] = Cluster{Signature: Signature(failure), Indexes: []int{i}}
+	}
+	return clusters
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"os"
+
+	"github.com/anthonydip/sherlock/internal/logger"
+	"github.com/anthonydip/sherlock/internal/notify"
+	"github.com/anthonydip/sherlock/internal/testrun"
+	"gopkg.in/yaml.v3"
+)
+
+// Default location of the configuration, relative to the repository root
+const DefaultPath = ".sherlock/config.yaml"
+
+// Project settings shared by everyone running sherlock on the repository
+//
+//	notifications:
+//	  - name: team-chat
+//	    type: slack
+//	    url: ${SLACK_WEBHOOK_URL}
+//	    only_new: true
+//	    branches: [main]
+//
+//	test:
+//	  command: npx jest
+//	  setup: npm ci
+type Config struct {
+	Notifications []notify.Channel `yaml:"notifications"`
+
+	// Command proposed patches are verified with
+	Test testrun.Config `yaml:"test"`
+}
+
+// Loads the configuration file. A missing file is an empty configuration.
+func Load(path string) (*Config, error) {
+	config := &Config{}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			logger.GlobalLogger.Debugf("No configuration at %s", path)
+			return config, nil
+		}
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+
+	logger.GlobalLogger.Verbosef("Loaded configuration from %s", path)
+	return config, nil
+}
@@ -0,0 +1,121 @@
+package forge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Longest error response body included in an error message
+const maxErrorBody = 200
+
+// JSON REST client shared by the forges
+type apiClient struct {
+	baseURL    string
+	authHeader string
+	authValue  string
+	httpClient *http.Client
+}
+
+func newAPIClient(baseURL, authHeader, authValue string) *apiClient {
+	return &apiClient{
+		baseURL:    baseURL,
+		authHeader: authHeader,
+		authValue:  authValue,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// Sends a request with an optional JSON body and decodes the JSON response
+// into out, if given
+func (c *apiClient) do(method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(c.authHeader, c.authValue)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message := strings.TrimSpace(string(data))
+		if len(message) > maxErrorBody {
+			message = message[:maxErrorBody] + "..."
+		}
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, message)
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%s %s: invalid response: %w", method, path, err)
+	}
+	return nil
+}
+
+// Fetches every comment of a paginated listing
+func (c *apiClient) listComments(path, pageSizeParam string, pageSize int) ([]Comment, error) {
+	var comments []Comment
+
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+
+	for page := 1; ; page++ {
+		var batch []Comment
+		if err := c.do("GET", fmt.Sprintf("%s%s%s=%d&page=%d", path, separator, pageSizeParam, pageSize, page), nil, &batch); err != nil {
+			return nil, err
+		}
+
+		comments = append(comments, batch...)
+		if len(batch) < pageSize {
+			return comments, nil
+		}
+	}
+}
+
+// Looks up the name of the user the token authenticates as, falling back to
+// the bot user for tokens that cannot read it
+func (c *apiClient) currentUser(botUser string) (string, error) {
+	var user Account
+	err := c.do("GET", "/user", nil, &user)
+	if err != nil || user.Name() == "" {
+		if botUser != "" {
+			return botUser, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("GET /user: no user name in response")
+		}
+		return "", err
+	}
+
+	return user.Name(), nil
+}
@@ -0,0 +1,154 @@
+package forge
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/anthonydip/sherlock/internal/report"
+)
+
+// Kept below the smallest comment size limit of the supported platforms
+// (GitHub allows 65536 characters)
+const maxCommentLength = 60000
+
+// Builds the pull request comment for a report. Failure locations link to the
+// files at the given commit, when known.
+func CommentBody(rep *report.Report, f Forge, commit string) string {
+	var builder strings.Builder
+	builder.WriteString(Marker + "\n")
+	builder.WriteString("## Sherlock Test Failure Report\n\n")
+
+	fmt.Fprintf(&builder, "**%d test failure(s)** in `%s`", len(rep.Failures), filepath.Base(rep.TestOutput))
+	if commit != "" {
+		fmt.Fprintf(&builder, " at %s", commit[:min(7, len(commit))])
+	}
+	builder.WriteString("\n")
+
+	if comparison := rep.Comparison; comparison != nil {
+		fmt.Fprintf(&builder, "\nCompared with the baseline: %d new, %d changed, %d still failing, %d fixed\n",
+			len(comparison.New), len(comparison.Changed), len(comparison.StillFailing), len(comparison.Fixed))
+	}
+
+	if len(rep.Failures) == 0 {
+		return builder.String()
+	}
+
+	links := make([]string, len(rep.Failures))
+	builder.WriteString("\n| # | Test | Location | Error |\n|---|---|---|---|\n")
+	for i, failure := range rep.Failures {
+		links[i] = location(rep, failure, f, commit)
+		fmt.Fprintf(&builder, "| %d | %s%s | %s | %s |\n", i+1, tableCell(failure.TestName), badges(failure), links[i], code(firstLine(failure.Error)))
+	}
+
+	// Failures of a cluster share the first member's analysis
+	firstInCluster := make(map[int]int)
+	for i, failure := range rep.Failures {
+		section := details(rep, failure, i+1, links[i], firstInCluster)
+
+		if builder.Len()+len(section) > maxCommentLength {
+			fmt.Fprintf(&builder, "\n_%d more failure(s) omitted, see the full report in the job output._\n", len(rep.Failures)-i)
+			break
+		}
+		builder.WriteString(section)
+	}
+
+	return builder.String()
+}
+
+// Builds the comment that replaces a previous report once every test passes
+func PassingBody(rep *report.Report) string {
+	return fmt.Sprintf("%s\n## Sherlock Test Failure Report\n\nAll tests in `%s` passed.\n", Marker, filepath.Base(rep.TestOutput))
+}
+
+// Collapsible section with the details and analysis of one failure
+func details(rep *report.Report, failure report.Failure, number int, link string, firstInCluster map[int]int) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "\n<details>\n<summary><b>%d. %s</b></summary>\n\n", number, escapeHTML(failure.TestName))
+
+	if link != "" {
+		fmt.Fprintf(&builder, "**Location:** %s\n\n", link)
+	}
+	if failure.Flaky != "" {
+		fmt.Fprintf(&builder, "> **Flaky test:** %s\n\n", failure.Flaky)
+	}
+	if failure.KnownIssue != "" {
+		fmt.Fprintf(&builder, "**Known issue:** `%s`\n\n", failure.KnownIssue)
+	}
+
+	fmt.Fprintf(&builder, "```\n%s\n```\n\n", strings.TrimSpace(strings.ReplaceAll(failure.Error, "```", "'''")))
+
+	first, shared := firstInCluster[failure.Cluster]
+	switch {
+	case failure.Cluster > 0 && shared:
+		fmt.Fprintf(&builder, "Same root cause as failure %d.\n\n", first)
+	default:
+		if failure.Cluster > 0 {
+			firstInCluster[failure.Cluster] = number
+		}
+		if analysis := rep.AnalysisFor(failure); analysis != "" {
+			builder.WriteString(analysis + "\n\n")
+		}
+	}
+
+	builder.WriteString("</details>\n")
+	return builder.String()
+}
+
+// Links the failing line to the file at the commit, or names it when no link
+// can be made
+func location(rep *report.Report, failure report.Failure, f Forge, commit string) string {
+	path := rep.RelativePath(failure)
+	if path == "" {
+		return ""
+	}
+
+	label := path
+	if failure.LineNumber > 0 {
+		label = fmt.Sprintf("%s:%d", path, failure.LineNumber)
+	}
+
+	// Files outside the repository have nothing to link to
+	if commit == "" || strings.HasPrefix(path, "/") {
+		return code(label)
+	}
+	return fmt.Sprintf("[`%s`](%s)", label, f.FileURL(commit, path, failure.LineNumber))
+}
+
+func badges(failure report.Failure) string {
+	var labels []string
+	if failure.Status != "" {
+		labels = append(labels, failure.Status)
+	}
+	if failure.Flaky != "" {
+		labels = append(labels, "flaky")
+	}
+	if failure.KnownIssue != "" {
+		labels = append(labels, "known issue")
+	}
+
+	if len(labels) == 0 {
+		return ""
+	}
+	return " _(" + strings.Join(labels, ", ") + ")_"
+}
+
+func code(text string) string {
+	if text == "" {
+		return ""
+	}
+	return "`" + strings.ReplaceAll(tableCell(text), "`", "'") + "`"
+}
+
+func tableCell(text string) string {
+	return strings.ReplaceAll(text, "|", "\\|")
+}
+
+func escapeHTML(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,104 @@
+package forge
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// Pull request ref checked out by GitHub Actions for pull_request events
+var pullRefRegex = regexp.MustCompile(`^refs/pull/(\d+)/`)
+
+// Fills in the options from the environment of the CI job, if any
+func Detect() Options {
+	switch {
+	case os.Getenv("GITEA_ACTIONS") == "true":
+		// Gitea Actions sets the GitHub Actions variables as well
+		opts := detectActions()
+		opts.Kind = Gitea
+		opts.BotUser = "gitea-actions"
+		return opts
+	case os.Getenv("GITHUB_ACTIONS") == "true":
+		opts := detectActions()
+		opts.Kind = GitHub
+		opts.BotUser = "github-actions[bot]"
+		return opts
+	case os.Getenv("GITLAB_CI") == "true":
+		return detectGitLab()
+	default:
+		return Options{}
+	}
+}
+
+func detectActions() Options {
+	opts := Options{
+		BaseURL: os.Getenv("GITHUB_API_URL"),
+		WebURL:  os.Getenv("GITHUB_SERVER_URL"),
+		Repo:    os.Getenv("GITHUB_REPOSITORY"),
+	}
+
+	// The event payload holds the pull request and its head commit, which
+	// differs from GITHUB_SHA, the merge commit the job runs on
+	if data, err := os.ReadFile(os.Getenv("GITHUB_EVENT_PATH")); err == nil {
+		var event struct {
+			PullRequest struct {
+				Number int `json:"number"`
+				Head   struct {
+					SHA string `json:"sha"`
+				} `json:"head"`
+			} `json:"pull_request"`
+		}
+		if json.Unmarshal(data, &event) == nil {
+			opts.Number = event.PullRequest.Number
+			opts.HeadSHA = event.PullRequest.Head.SHA
+		}
+	}
+
+	if opts.Number == 0 {
+		if match := pullRefRegex.FindStringSubmatch(os.Getenv("GITHUB_REF")); match != nil {
+			opts.Number, _ = strconv.Atoi(match[1])
+		}
+	}
+
+	return opts
+}
+
+func detectGitLab() Options {
+	opts := Options{
+		Kind:    GitLab,
+		BaseURL: os.Getenv("CI_API_V4_URL"),
+		WebURL:  os.Getenv("CI_SERVER_URL"),
+		Repo:    os.Getenv("CI_PROJECT_PATH"),
+		HeadSHA: os.Getenv("CI_MERGE_REQUEST_SOURCE_BRANCH_SHA"),
+	}
+	opts.Number, _ = strconv.Atoi(os.Getenv("CI_MERGE_REQUEST_IID"))
+
+	// Merged results pipelines only set the source branch commit, other
+	// merge request pipelines run on the head commit itself
+	if opts.HeadSHA == "" && opts.Number > 0 {
+		opts.HeadSHA = os.Getenv("CI_COMMIT_SHA")
+	}
+
+	return opts
+}
+
+// Looks up the API token, preferring the sherlock specific variable
+func Token(kind string) string {
+	if token := os.Getenv("SHERLOCK_FORGE_TOKEN"); token != "" {
+		return token
+	}
+	return os.Getenv(tokenVariable(kind))
+}
+
+// Environment variable holding the platform's token
+func tokenVariable(kind string) string {
+	switch kind {
+	case GitLab:
+		return "GITLAB_TOKEN"
+	case Gitea:
+		return "GITEA_TOKEN"
+	default:
+		return "GITHUB_TOKEN"
+	}
+}
@@ -0,0 +1,151 @@
+package forge
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Supported code hosting platforms
+const (
+	GitHub = "github"
+	GitLab = "gitlab"
+	Gitea  = "gitea"
+)
+
+var Kinds = []string{GitHub, GitLab, Gitea}
+
+// Hidden marker identifying the comment sherlock maintains on a pull request
+const Marker = "<!-- sherlock:report -->"
+
+type Options struct {
+	Kind string
+
+	// Root of the REST API, e.g. https://api.github.com
+	BaseURL string
+
+	// Root of the web interface file links point to, derived from the API
+	// root when empty
+	WebURL string
+
+	Token string
+
+	// owner/name on GitHub and Gitea, the project path or ID on GitLab
+	Repo string
+
+	// Pull request number, or merge request IID on GitLab
+	Number int
+
+	// Commit file links point to, looked up from the pull request when empty
+	HeadSHA string
+
+	// User the token comments as when it cannot look up its own user, as
+	// with the GitHub Actions token
+	BotUser string
+}
+
+// User account, named by login on GitHub and Gitea and username on GitLab
+type Account struct {
+	Login    string `json:"login"`
+	Username string `json:"username"`
+}
+
+func (a Account) Name() string {
+	if a.Login != "" {
+		return a.Login
+	}
+	return a.Username
+}
+
+type Comment struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+
+	// Author, "user" on GitHub and Gitea and "author" on GitLab
+	User   Account `json:"user"`
+	Author Account `json:"author"`
+}
+
+func (c Comment) AuthorName() string {
+	if name := c.User.Name(); name != "" {
+		return name
+	}
+	return c.Author.Name()
+}
+
+// Pull request of a code hosting platform
+type Forge interface {
+	Comments() ([]Comment, error)
+	CreateComment(body string) error
+	UpdateComment(id int64, body string) error
+
+	// Name of the user the token authenticates as
+	CurrentUser() (string, error)
+
+	// Head commit of the pull request
+	HeadCommit() (string, error)
+
+	// Web link to a line of a file at a commit
+	FileURL(commit, path string, line int) string
+}
+
+func New(opts Options) (Forge, error) {
+	if opts.Repo == "" {
+		return nil, fmt.Errorf("no repository set for %s (use --forge-repo)", opts.Kind)
+	}
+	if opts.Number <= 0 {
+		return nil, fmt.Errorf("no pull request number set for %s (use --pr)", opts.Kind)
+	}
+	if opts.Token == "" {
+		return nil, fmt.Errorf("no %s token found (set SHERLOCK_FORGE_TOKEN or %s)", opts.Kind, tokenVariable(opts.Kind))
+	}
+
+	opts.BaseURL = strings.TrimSuffix(opts.BaseURL, "/")
+	opts.WebURL = strings.TrimSuffix(opts.WebURL, "/")
+
+	switch opts.Kind {
+	case GitHub:
+		return newGitHub(opts), nil
+	case Gitea:
+		if opts.BaseURL == "" {
+			return nil, fmt.Errorf("gitea has no default API URL (use --forge-url)")
+		}
+		return newGitea(opts), nil
+	case GitLab:
+		return newGitLab(opts), nil
+	default:
+		return nil, fmt.Errorf("unsupported forge '%s' (supported: %s)", opts.Kind, strings.Join(Kinds, ", "))
+	}
+}
+
+// Creates the sherlock comment on the pull request, or updates the one a
+// previous run posted. With create unset, only an existing comment is
+// updated. Returns whether a comment was written.
+func PostComment(f Forge, body string, create bool) (bool, error) {
+	// Comments quoting the marker may come from anyone, only our own are updated
+	user, err := f.CurrentUser()
+	if err != nil {
+		return false, fmt.Errorf("failed to look up the authenticated user: %w", err)
+	}
+
+	comments, err := f.Comments()
+	if err != nil {
+		return false, fmt.Errorf("failed to list comments: %w", err)
+	}
+
+	for _, comment := range comments {
+		if strings.EqualFold(comment.AuthorName(), user) && strings.Contains(comment.Body, Marker) {
+			if err := f.UpdateComment(comment.ID, body); err != nil {
+				return false, fmt.Errorf("failed to update comment %d: %w", comment.ID, err)
+			}
+			return true, nil
+		}
+	}
+
+	if !create {
+		return false, nil
+	}
+	if err := f.CreateComment(body); err != nil {
+		return false, fmt.Errorf("failed to create comment: %w", err)
+	}
+	return true, nil
+}
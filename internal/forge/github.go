@@ -0,0 +1,138 @@
+package forge
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const defaultGitHubURL = "https://api.github.com"
+
+// GitHub pull request. Gitea implements the same API with a different
+// authorization scheme, page size parameter and file link layout.
+type githubForge struct {
+	api    *apiClient
+	repo   string
+	number int
+	head   string
+	webURL string
+
+	user    string
+	botUser string
+
+	pageSizeParam string
+	pageSize      int
+	blobPath      string
+}
+
+func newGitHub(opts Options) *githubForge {
+	baseURL := opts.BaseURL
+	if baseURL == "" {
+		baseURL = defaultGitHubURL
+	}
+
+	webURL := opts.WebURL
+	if webURL == "" {
+		// GitHub Enterprise serves the API under /api/v3
+		if baseURL == defaultGitHubURL {
+			webURL = "https://github.com"
+		} else {
+			webURL = strings.TrimSuffix(baseURL, "/api/v3")
+		}
+	}
+
+	return &githubForge{
+		api:           newAPIClient(baseURL, "Authorization", "Bearer "+opts.Token),
+		repo:          opts.Repo,
+		number:        opts.Number,
+		head:          opts.HeadSHA,
+		webURL:        webURL,
+		botUser:       opts.BotUser,
+		pageSizeParam: "per_page",
+		pageSize:      100,
+		blobPath:      "blob",
+	}
+}
+
+func newGitea(opts Options) *githubForge {
+	webURL := opts.WebURL
+	if webURL == "" {
+		webURL = strings.TrimSuffix(opts.BaseURL, "/api/v1")
+	}
+
+	return &githubForge{
+		api:           newAPIClient(opts.BaseURL, "Authorization", "token "+opts.Token),
+		repo:          opts.Repo,
+		number:        opts.Number,
+		head:          opts.HeadSHA,
+		webURL:        webURL,
+		botUser:       opts.BotUser,
+		pageSizeParam: "limit",
+		pageSize:      50,
+		blobPath:      "src/commit",
+	}
+}
+
+func (f *githubForge) Comments() ([]Comment, error) {
+	return f.api.listComments(fmt.Sprintf("/repos/%s/issues/%d/comments", f.repo, f.number), f.pageSizeParam, f.pageSize)
+}
+
+func (f *githubForge) CreateComment(body string) error {
+	return f.api.do("POST", fmt.Sprintf("/repos/%s/issues/%d/comments", f.repo, f.number), map[string]string{"body": body}, nil)
+}
+
+func (f *githubForge) UpdateComment(id int64, body string) error {
+	return f.api.do("PATCH", fmt.Sprintf("/repos/%s/issues/comments/%d", f.repo, id), map[string]string{"body": body}, nil)
+}
+
+func (f *githubForge) CurrentUser() (string, error) {
+	if f.user != "" {
+		return f.user, nil
+	}
+
+	user, err := f.api.currentUser(f.botUser)
+	if err != nil {
+		return "", err
+	}
+
+	f.user = user
+	return f.user, nil
+}
+
+func (f *githubForge) HeadCommit() (string, error) {
+	if f.head != "" {
+		return f.head, nil
+	}
+
+	var pull struct {
+		Head struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+	}
+	if err := f.api.do("GET", fmt.Sprintf("/repos/%s/pulls/%d", f.repo, f.number), nil, &pull); err != nil {
+		return "", err
+	}
+	if pull.Head.SHA == "" {
+		return "", fmt.Errorf("pull request %d has no head commit", f.number)
+	}
+
+	f.head = pull.Head.SHA
+	return f.head, nil
+}
+
+func (f *githubForge) FileURL(commit, path string, line int) string {
+	link := fmt.Sprintf("%s/%s/%s/%s/%s", f.webURL, f.repo, f.blobPath, commit, escapePath(path))
+	if line > 0 {
+		link += fmt.Sprintf("#L%d", line)
+	}
+	return link
+}
+
+// Escapes each segment of a slash separated path for use in a URL
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,125 @@
+package forge
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const defaultGitLabURL = "https://gitlab.com/api/v4"
+
+// GitLab merge request, commented on through notes
+type gitlabForge struct {
+	api     *apiClient
+	project string
+	number  int
+	head    string
+	webURL  string
+
+	// Path of the project with its namespace, for web links
+	projectPath string
+
+	user    string
+	botUser string
+}
+
+func newGitLab(opts Options) *gitlabForge {
+	baseURL := opts.BaseURL
+	if baseURL == "" {
+		baseURL = defaultGitLabURL
+	}
+
+	webURL := opts.WebURL
+	if webURL == "" {
+		webURL = strings.TrimSuffix(baseURL, "/api/v4")
+	}
+
+	return &gitlabForge{
+		api:     newAPIClient(baseURL, "PRIVATE-TOKEN", opts.Token),
+		project: opts.Repo,
+		number:  opts.Number,
+		head:    opts.HeadSHA,
+		webURL:  webURL,
+		botUser: opts.BotUser,
+	}
+}
+
+// Projects are addressed by ID or by their URL-encoded path
+func (f *gitlabForge) mergeRequestPath() string {
+	return fmt.Sprintf("/projects/%s/merge_requests/%d", url.PathEscape(f.project), f.number)
+}
+
+func (f *gitlabForge) Comments() ([]Comment, error) {
+	return f.api.listComments(f.mergeRequestPath()+"/notes", "per_page", 100)
+}
+
+func (f *gitlabForge) CreateComment(body string) error {
+	return f.api.do("POST", f.mergeRequestPath()+"/notes", map[string]string{"body": body}, nil)
+}
+
+func (f *gitlabForge) UpdateComment(id int64, body string) error {
+	return f.api.do("PUT", fmt.Sprintf("%s/notes/%d", f.mergeRequestPath(), id), map[string]string{"body": body}, nil)
+}
+
+func (f *gitlabForge) CurrentUser() (string, error) {
+	if f.user != "" {
+		return f.user, nil
+	}
+
+	user, err := f.api.currentUser(f.botUser)
+	if err != nil {
+		return "", err
+	}
+
+	f.user = user
+	return f.user, nil
+}
+
+func (f *gitlabForge) HeadCommit() (string, error) {
+	if f.head != "" {
+		return f.head, nil
+	}
+
+	var mergeRequest struct {
+		SHA string `json:"sha"`
+	}
+	if err := f.api.do("GET", f.mergeRequestPath(), nil, &mergeRequest); err != nil {
+		return "", err
+	}
+	if mergeRequest.SHA == "" {
+		return "", fmt.Errorf("merge request %d has no head commit", f.number)
+	}
+
+	f.head = mergeRequest.SHA
+	return f.head, nil
+}
+
+func (f *gitlabForge) FileURL(commit, path string, line int) string {
+	link := fmt.Sprintf("%s/%s/-/blob/%s/%s", f.webURL, f.webPath(), commit, escapePath(path))
+	if line > 0 {
+		link += fmt.Sprintf("#L%d", line)
+	}
+	return link
+}
+
+// Returns the project path web links use, looked up once when the project
+// is given by its numeric ID
+func (f *gitlabForge) webPath() string {
+	if f.projectPath != "" {
+		return f.projectPath
+	}
+
+	f.projectPath = f.project
+	if _, err := strconv.Atoi(f.project); err != nil {
+		return f.projectPath
+	}
+
+	var project struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	}
+	if err := f.api.do("GET", "/projects/"+f.project, nil, &project); err == nil && project.PathWithNamespace != "" {
+		f.projectPath = project.PathWithNamespace
+	}
+	return f.projectPath
+}
@@ -3,24 +3,126 @@ package git
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/anthonydip/sherlock/internal/logger"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/format/diff"
 	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
-func (r *Repository) GetBlame(path string) (*git.BlameResult, error) {
+func (r *Repository) GetBlame(path string) (*BlameResult, error) {
+	result, err := r.blameAtHead(path)
+	if !r.includeWorktree {
+		return result, err
+	}
+
+	// Files that only exist in the working tree have no HEAD blame
+	if err == object.ErrFileNotFound {
+		result, err = &BlameResult{Path: path}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return r.overlayWorktree(result)
+}
+
+func (r *Repository) blameAtHead(path string) (*BlameResult, error) {
 	head, err := r.repo.Head()
 	if err != nil {
 		return nil, err
 	}
 
-	commit, err := r.repo.CommitObject(head.Hash())
+	return r.blameAt(head.Hash(), path)
+}
+
+// Blames a file at the given commit, reusing cached results
+func (r *Repository) blameAt(hash plumbing.Hash, path string) (*BlameResult, error) {
+	if result, ok := r.cache.getBlame(hash, path); ok {
+		return result, nil
+	}
+
+	commit, err := r.repo.CommitObject(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.GlobalLogger.Debugf("Computing blame for %s at %s", path, commit.Hash.String()[:7])
+	blame, err := git.Blame(commit, path)
 	if err != nil {
 		return nil, err
 	}
 
-	return git.Blame(commit, path)
+	result := &BlameResult{Path: path}
+	for _, line := range blame.Lines {
+		result.Lines = append(result.Lines, BlameLine{
+			Hash:       line.Hash,
+			Author:     line.Author,
+			AuthorName: line.AuthorName,
+			Date:       line.Date,
+			Text:       line.Text,
+			Path:       path,
+		})
+	}
+
+	// Record the file's previous paths for lines predating a rename
+	if err := r.resolveBlamePaths(result, commit.Hash); err != nil {
+		logger.GlobalLogger.Debugf("Failed to resolve renamed paths of %s: %v", path, err)
+	}
+
+	// Attribute reformatted lines to the change before the reformat
+	if err := r.lookThroughIgnoredCommits(result); err != nil {
+		logger.GlobalLogger.Debugf("Failed to look through ignored commits in %s: %v", path, err)
+	}
+
+	r.cache.putBlame(commit.Hash, path, result)
+	return result, nil
+}
+
+// Maps HEAD blame onto the working tree version of the file, marking
+// lines that only exist in the working tree as uncommitted
+func (r *Repository) overlayWorktree(headBlame *BlameResult) (*BlameResult, error) {
+	headContent, worktreeContent, err := r.worktreeFileVersions(headBlame.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	mapping := mapLines(headContent, worktreeContent)
+	worktreeLines := splitLines(worktreeContent)
+
+	result := &BlameResult{Path: headBlame.Path}
+	for i, text := range worktreeLines {
+		if old := mapping[i]; old >= 0 && old < len(headBlame.Lines) {
+			result.Lines = append(result.Lines, headBlame.Lines[old])
+			continue
+		}
+
+		result.Lines = append(result.Lines, BlameLine{
+			Hash:        plumbing.ZeroHash,
+			Author:      "not.committed.yet",
+			AuthorName:  "Not Committed Yet",
+			Date:        time.Now(),
+			Text:        text,
+			Path:        headBlame.Path,
+			Uncommitted: true,
+		})
+	}
+
+	return result, nil
+}
+
+func uncommittedCommitInfo(path string) CommitInfo {
+	return CommitInfo{
+		Hash:        plumbing.ZeroHash.String(),
+		Author:      "Not Committed Yet",
+		Date:        time.Now(),
+		Message:     "Uncommitted working tree changes",
+		Changes:     []string{path},
+		Path:        path,
+		Uncommitted: true,
+	}
 }
 
 func (r *Repository) GetCommitsAffectingLines(path string, lines []int, limit int) ([]CommitInfo, error) {
@@ -43,29 +145,40 @@ func (r *Repository) GetCommitsAffectingLines(path string, lines []int, limit in
 		}
 		seen[commitHash] = true
 
+		if blame.Lines[line-1].Uncommitted {
+			commits = append(commits, uncommittedCommitInfo(path))
+			if len(commits) >= limit {
+				break
+			}
+			continue
+		}
+
 		commit, err := r.repo.CommitObject(blame.Lines[line-1].Hash)
 		if err != nil {
 			continue
 		}
 
-		fileIter, err := commit.Files()
+		// Files the commit changed, not every file in its tree
+		changes, err := commitChanges(commit)
 		if err != nil {
 			continue
 		}
 
-		var changes []string
-		fileIter.ForEach(func(f *object.File) error {
-			changes = append(changes, f.Name)
-			return nil
-		})
-
-		commits = append(commits, CommitInfo{
+		info := CommitInfo{
 			Hash:    commitHash,
 			Author:  commit.Author.String(),
 			Date:    commit.Author.When,
 			Message: strings.TrimSpace(commit.Message),
-			Changes: changes,
-		})
+			Changes: changedNames(changes),
+			Path:    blame.Lines[line-1].Path,
+		}
+
+		// Record the previous path if the commit renamed the file
+		if previous, err := r.findRename(commit, info.Path); err == nil {
+			info.PreviousPath = previous
+		}
+
+		commits = append(commits, info)
 
 		if len(commits) >= limit {
 			break
@@ -85,6 +198,10 @@ func (r *Repository) GetLineChanges(path string, line int) (string, error) {
 		return "", fmt.Errorf("line out of range")
 	}
 
+	if blame.Lines[line-1].Uncommitted {
+		return r.getUncommittedLineChanges(path, line)
+	}
+
 	commit, err := r.repo.CommitObject(blame.Lines[line-1].Hash)
 	if err != nil {
 		return "", err
@@ -100,10 +217,19 @@ func (r *Repository) GetLineChanges(path string, line int) (string, error) {
 		return "", err
 	}
 
+	// Path of the file in the blamed commit, if it was renamed since
+	linePath := blame.Lines[line-1].Path
+
 	// Check if file was added in this commit
-	_, err = parent.File(path)
+	_, err = parent.File(linePath)
 	if err == object.ErrFileNotFound {
-		return fmt.Sprintf("+ %s (file added in this commit)", blame.Lines[line-1].Text), nil
+		previous, err := r.findRename(commit, linePath)
+		if err != nil {
+			return "", err
+		}
+		if previous == "" {
+			return fmt.Sprintf("+ %s (file added in this commit)", blame.Lines[line-1].Text), nil
+		}
 	} else if err != nil {
 		return "", err
 	}
@@ -119,7 +245,7 @@ func (r *Repository) GetLineChanges(path string, line int) (string, error) {
 
 	for _, filePatch := range patch.FilePatches() {
 		_, to := filePatch.Files()
-		if to == nil || to.Path() != path {
+		if to == nil || to.Path() != linePath {
 			continue
 		}
 
@@ -144,3 +270,19 @@ func (r *Repository) GetLineChanges(path string, line int) (string, error) {
 
 	return changes.String(), nil
 }
+
+// Returns the uncommitted hunk containing the given working tree line
+func (r *Repository) getUncommittedLineChanges(path string, line int) (string, error) {
+	fileDiff, err := r.getWorktreeFileDiff(path)
+	if err != nil {
+		return "", err
+	}
+
+	for _, hunk := range fileDiff.Hunks {
+		if hunk.ContainsLine(line) {
+			return fmt.Sprintf("(uncommitted change)\n%s", hunk.String()), nil
+		}
+	}
+
+	return "", fmt.Errorf("no uncommitted change found for line %d", line)
+}
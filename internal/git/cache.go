@@ -0,0 +1,271 @@
+package git
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anthonydip/sherlock/internal/logger"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+// On-disk cache entries unused for this long are removed
+const diskCacheMaxAge = 14 * 24 * time.Hour
+
+// Blame and history results shared across failures within a run, backed by
+// an optional on-disk cache under .git/sherlock/ for repeated runs
+type repoCache struct {
+	mutex   sync.Mutex
+	blame   map[string]*BlameResult
+	history map[string][]historyEntry
+
+	// Root of the on-disk cache, empty when disabled
+	dir string
+
+	// Distinguishes blame entries computed with different ignored revisions
+	salt string
+}
+
+type cachedBlameLine struct {
+	Hash       string    `json:"hash"`
+	Author     string    `json:"author"`
+	AuthorName string    `json:"author_name"`
+	Date       time.Time `json:"date"`
+	Text       string    `json:"text"`
+	Path       string    `json:"path"`
+}
+
+func newRepoCache(dir string) *repoCache {
+	return &repoCache{
+		blame:   make(map[string]*BlameResult),
+		history: make(map[string][]historyEntry),
+		dir:     dir,
+	}
+}
+
+// Returns the .git directory of the repository, or an empty string when the
+// repository is not stored on the filesystem. Linked worktrees resolve to the
+// main repository's directory so they share its cache.
+func (r *Repository) gitDir() string {
+	storage, ok := r.repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return ""
+	}
+
+	dir := storage.Filesystem().Root()
+	if content, err := os.ReadFile(filepath.Join(dir, "commondir")); err == nil {
+		common := strings.TrimSpace(string(content))
+		if !filepath.IsAbs(common) {
+			common = filepath.Join(dir, common)
+		}
+		return filepath.Clean(common)
+	}
+
+	return dir
+}
+
+// Returns the path of the run history kept under .git/sherlock/, or an empty
+// string when the repository is not stored on the filesystem
+func (r *Repository) HistoryPath() string {
+	if r.gitDir() == "" {
+		return ""
+	}
+	return filepath.Join(r.gitDir(), "sherlock", "history.jsonl")
+}
+
+// Enables or disables the on-disk blame and history cache
+func (r *Repository) UseDiskCache(enabled bool) {
+	r.cache.mutex.Lock()
+	defer r.cache.mutex.Unlock()
+
+	r.cache.dir = ""
+	if enabled && r.gitDir() != "" {
+		r.cache.dir = filepath.Join(r.gitDir(), "sherlock", "cache")
+		r.cache.prune()
+	}
+}
+
+func cacheKey(commit plumbing.Hash, path string) string {
+	return commit.String() + ":" + path
+}
+
+// Returns the file name of a cache entry for a path
+func pathDigest(path string) string {
+	sum := sha1.Sum([]byte(path))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *repoCache) getBlame(commit plumbing.Hash, path string) (*BlameResult, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := cacheKey(commit, path)
+	if result, ok := c.blame[key]; ok {
+		logger.GlobalLogger.Debugf("Blame cache hit for %s at %s", path, commit.String()[:7])
+		return result, true
+	}
+
+	if c.dir == "" {
+		return nil, false
+	}
+
+	var lines []cachedBlameLine
+	if !c.readDisk(filepath.Join("blame", commit.String(), pathDigest(c.salt+path)+".json"), &lines) {
+		return nil, false
+	}
+
+	result := &BlameResult{Path: path}
+	for _, line := range lines {
+		result.Lines = append(result.Lines, BlameLine{
+			Hash:       plumbing.NewHash(line.Hash),
+			Author:     line.Author,
+			AuthorName: line.AuthorName,
+			Date:       line.Date,
+			Text:       line.Text,
+			Path:       line.Path,
+		})
+	}
+
+	logger.GlobalLogger.Debugf("Disk blame cache hit for %s at %s", path, commit.String()[:7])
+	c.blame[key] = result
+	return result, true
+}
+
+func (c *repoCache) putBlame(commit plumbing.Hash, path string, result *BlameResult) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.blame[cacheKey(commit, path)] = result
+
+	if c.dir == "" {
+		return
+	}
+
+	lines := make([]cachedBlameLine, 0, len(result.Lines))
+	for _, line := range result.Lines {
+		lines = append(lines, cachedBlameLine{
+			Hash:       line.Hash.String(),
+			Author:     line.Author,
+			AuthorName: line.AuthorName,
+			Date:       line.Date,
+			Text:       line.Text,
+			Path:       line.Path,
+		})
+	}
+
+	c.writeDisk(filepath.Join("blame", commit.String(), pathDigest(c.salt+path)+".json"), lines)
+}
+
+func (c *repoCache) getHistory(head plumbing.Hash, path string, limit int) ([]historyEntry, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := fmt.Sprintf("%s:%d", cacheKey(head, path), limit)
+	if entries, ok := c.history[key]; ok {
+		logger.GlobalLogger.Debugf("History cache hit for %s", path)
+		return entries, true
+	}
+
+	if c.dir == "" {
+		return nil, false
+	}
+
+	var entries []historyEntry
+	if !c.readDisk(filepath.Join("history", head.String(), fmt.Sprintf("%s-%d.json", pathDigest(path), limit)), &entries) {
+		return nil, false
+	}
+
+	logger.GlobalLogger.Debugf("Disk history cache hit for %s", path)
+	c.history[key] = entries
+	return entries, true
+}
+
+func (c *repoCache) putHistory(head plumbing.Hash, path string, limit int, entries []historyEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.history[fmt.Sprintf("%s:%d", cacheKey(head, path), limit)] = entries
+
+	if c.dir == "" {
+		return
+	}
+
+	c.writeDisk(filepath.Join("history", head.String(), fmt.Sprintf("%s-%d.json", pathDigest(path), limit)), entries)
+}
+
+func (c *repoCache) readDisk(name string, v interface{}) bool {
+	path := filepath.Join(c.dir, name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	// Mark the commit directory as recently used so it survives pruning
+	now := time.Now()
+	os.Chtimes(filepath.Dir(path), now, now)
+
+	if err := json.Unmarshal(data, v); err != nil {
+		logger.GlobalLogger.Debugf("Ignoring corrupt cache entry %s: %v", name, err)
+		return false
+	}
+
+	return true
+}
+
+// Cache write failures only cost performance, so they are logged and ignored
+func (c *repoCache) writeDisk(name string, v interface{}) {
+	path := filepath.Join(c.dir, name)
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		logger.GlobalLogger.Debugf("Failed to encode cache entry %s: %v", name, err)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		logger.GlobalLogger.Debugf("Failed to create cache directory: %v", err)
+		return
+	}
+
+	// Write atomically so concurrent runs never read partial entries
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		logger.GlobalLogger.Debugf("Failed to write cache entry %s: %v", name, err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		logger.GlobalLogger.Debugf("Failed to write cache entry %s: %v", name, err)
+		os.Remove(tmp)
+	}
+}
+
+// Removes per-commit cache directories that have not been used recently
+func (c *repoCache) prune() {
+	if c.dir == "" {
+		return
+	}
+
+	for _, kind := range []string{"blame", "history"} {
+		entries, err := os.ReadDir(filepath.Join(c.dir, kind))
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil || time.Since(info.ModTime()) < diskCacheMaxAge {
+				continue
+			}
+
+			logger.GlobalLogger.Debugf("Pruning stale cache entry %s/%s", kind, entry.Name())
+			os.RemoveAll(filepath.Join(c.dir, kind, entry.Name()))
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/anthonydip/sherlock/internal/logger"
+)
+
+// Runs the git executable within the repository, for operations go-git
+// does not support (e.g. linked worktrees)
+func (r *Repository) runGit(args ...string) (string, error) {
+	return runGitIn(r.path, "", args...)
+}
+
+// Runs git in a directory, such as a linked worktree, with the given input
+func runGitIn(dir string, input string, args ...string) (string, error) {
+	logger.GlobalLogger.Debugf("Running git %s", strings.Join(args, " "))
+
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if input != "" {
+		cmd.Stdin = strings.NewReader(input)
+	}
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+
+	return stdout.String(), nil
+}
@@ -0,0 +1,286 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/anthonydip/sherlock/internal/logger"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// Number of unchanged lines kept around each hunk
+const diffContextLines = 3
+
+// Branches tried, in order, when no base ref is given
+var defaultBaseBranches = []string{"main", "master", "origin/main", "origin/master"}
+
+// Resolves the commit to diff against: the merge-base of HEAD with ref,
+// or with main/master when ref is empty
+func (r *Repository) ResolveBase(ref string) (*object.Commit, error) {
+	head, err := r.repo.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	headCommit, err := r.repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []string{ref}
+	if ref == "" {
+		candidates = defaultBaseBranches
+	}
+
+	for _, candidate := range candidates {
+		hash, err := r.repo.ResolveRevision(plumbing.Revision(candidate))
+		if err != nil {
+			logger.GlobalLogger.Debugf("Unable to resolve base candidate %s: %v", candidate, err)
+			continue
+		}
+
+		baseCommit, err := r.repo.CommitObject(*hash)
+		if err != nil {
+			return nil, err
+		}
+
+		bases, err := headCommit.MergeBase(baseCommit)
+		if err != nil {
+			return nil, err
+		}
+		if len(bases) == 0 {
+			return nil, fmt.Errorf("no common ancestor between HEAD and %s", candidate)
+		}
+
+		logger.GlobalLogger.Debugf("Resolved base %s to merge-base %s", candidate, bases[0].Hash.String()[:7])
+		return bases[0], nil
+	}
+
+	if ref != "" {
+		return nil, fmt.Errorf("unable to resolve base ref %q", ref)
+	}
+	return nil, fmt.Errorf("unable to find a default base branch (tried %s)", strings.Join(defaultBaseBranches, ", "))
+}
+
+// Computes the changes between the base commit and HEAD
+func (r *Repository) GetBranchDiff(base *object.Commit) ([]FileDiff, error) {
+	head, err := r.repo.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	headCommit, err := r.repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	baseTree, err := base.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := object.DiffTreeWithOptions(context.Background(), baseTree, headTree, object.DefaultDiffTreeOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []FileDiff
+	for _, change := range changes {
+		from, to, err := change.Files()
+		if err != nil {
+			return nil, err
+		}
+
+		var fileDiff FileDiff
+		var oldContent, newContent string
+
+		// File names only hold the base name, so take full paths from the change
+		if from != nil {
+			fileDiff.OldPath = change.From.Name
+			if oldContent, err = textContents(from); err != nil {
+				logger.GlobalLogger.Debugf("Skipping %s in branch diff: %v", change.From.Name, err)
+				continue
+			}
+		}
+		if to != nil {
+			fileDiff.Path = change.To.Name
+			if newContent, err = textContents(to); err != nil {
+				logger.GlobalLogger.Debugf("Skipping %s in branch diff: %v", change.To.Name, err)
+				continue
+			}
+		}
+
+		fileDiff.Hunks = diffContents(oldContent, newContent)
+		diffs = append(diffs, fileDiff)
+	}
+
+	logger.GlobalLogger.Debugf("Branch diff against %s touches %d file(s)", base.Hash.String()[:7], len(diffs))
+	return diffs, nil
+}
+
+func textContents(file *object.File) (string, error) {
+	binary, err := file.IsBinary()
+	if err != nil {
+		return "", err
+	}
+	if binary {
+		return "", fmt.Errorf("binary file")
+	}
+	return file.Contents()
+}
+
+type diffLine struct {
+	op   diffmatchpatch.Operation
+	text string
+}
+
+// Computes a line diff between two versions of a file and groups it into hunks
+func diffContents(oldContent, newContent string) []Hunk {
+	var lines []diffLine
+	for _, d := range diff.Do(oldContent, newContent) {
+		for _, text := range strings.SplitAfter(d.Text, "\n") {
+			if text == "" {
+				continue
+			}
+			lines = append(lines, diffLine{op: d.Type, text: strings.TrimSuffix(text, "\n")})
+		}
+	}
+
+	// Line numbers preceding each diff line
+	oldAt := make([]int, len(lines)+1)
+	newAt := make([]int, len(lines)+1)
+	for i, line := range lines {
+		oldAt[i+1], newAt[i+1] = oldAt[i], newAt[i]
+		if line.op != diffmatchpatch.DiffInsert {
+			oldAt[i+1]++
+		}
+		if line.op != diffmatchpatch.DiffDelete {
+			newAt[i+1]++
+		}
+	}
+
+	var hunks []Hunk
+	for i := 0; i < len(lines); {
+		if lines[i].op == diffmatchpatch.DiffEqual {
+			i++
+			continue
+		}
+
+		// Extend the hunk while changes are close enough to share context
+		end := i
+		for j := i; j < len(lines); j++ {
+			if lines[j].op != diffmatchpatch.DiffEqual {
+				end = j + 1
+			} else if j-end >= 2*diffContextLines {
+				break
+			}
+		}
+
+		start := max(0, i-diffContextLines)
+		stop := min(len(lines), end+diffContextLines)
+
+		hunk := Hunk{
+			OldStart: oldAt[start] + 1,
+			OldLines: oldAt[stop] - oldAt[start],
+			NewStart: newAt[start] + 1,
+			NewLines: newAt[stop] - newAt[start],
+		}
+
+		// Empty ranges refer to the line before, as in unified diffs
+		if hunk.OldLines == 0 {
+			hunk.OldStart--
+		}
+		if hunk.NewLines == 0 {
+			hunk.NewStart--
+		}
+		for _, line := range lines[start:stop] {
+			switch line.op {
+			case diffmatchpatch.DiffInsert:
+				hunk.Lines = append(hunk.Lines, "+"+line.text)
+			case diffmatchpatch.DiffDelete:
+				hunk.Lines = append(hunk.Lines, "-"+line.text)
+			default:
+				hunk.Lines = append(hunk.Lines, " "+line.text)
+			}
+		}
+
+		hunks = append(hunks, hunk)
+		i = stop
+	}
+
+	return hunks
+}
+
+// Reports whether the given line of the new version falls within the hunk
+func (h Hunk) ContainsLine(line int) bool {
+	return h.NewLines > 0 && line >= h.NewStart && line < h.NewStart+h.NewLines
+}
+
+func (h Hunk) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", h.OldStart, h.OldLines, h.NewStart, h.NewLines))
+	for _, line := range h.Lines {
+		sb.WriteString(line + "\n")
+	}
+	return sb.String()
+}
+
+func (d FileDiff) String() string {
+	var sb strings.Builder
+
+	oldPath, newPath := "a/"+d.OldPath, "b/"+d.Path
+	if d.OldPath == "" {
+		oldPath = "/dev/null"
+	}
+	if d.Path == "" {
+		newPath = "/dev/null"
+	}
+
+	sb.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", oldPath, newPath))
+	for _, hunk := range d.Hunks {
+		sb.WriteString(hunk.String())
+	}
+	return sb.String()
+}
+
+// Maps each line of the new content to its line in the old content, or -1
+// when the line was added
+func mapLines(oldContent, newContent string) []int {
+	var mapping []int
+	oldLine := 0
+
+	for _, d := range diff.Do(oldContent, newContent) {
+		count := len(splitLines(d.Text))
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			for i := 0; i < count; i++ {
+				mapping = append(mapping, oldLine+i)
+			}
+			oldLine += count
+		case diffmatchpatch.DiffInsert:
+			for i := 0; i < count; i++ {
+				mapping = append(mapping, -1)
+			}
+		case diffmatchpatch.DiffDelete:
+			oldLine += count
+		}
+	}
+
+	return mapping
+}
+
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
@@ -0,0 +1,51 @@
+package git
+
+import (
+	"regexp"
+
+	"github.com/go-git/go-git/v5"
+)
+
+type GrepMatch struct {
+	Path string
+	Line int
+	Text string
+}
+
+// Searches the files committed at HEAD for lines matching the pattern,
+// optionally only in paths matching pathPattern. Returns at most limit
+// matches, and whether more were found.
+func (r *Repository) Grep(pattern *regexp.Regexp, pathPattern *regexp.Regexp, limit int) ([]GrepMatch, bool, error) {
+	worktree, err := r.repo.Worktree()
+	if err != nil {
+		return nil, false, err
+	}
+
+	head, err := r.repo.Head()
+	if err != nil {
+		return nil, false, err
+	}
+
+	opts := &git.GrepOptions{
+		Patterns:   []*regexp.Regexp{pattern},
+		CommitHash: head.Hash(),
+	}
+	if pathPattern != nil {
+		opts.PathSpecs = []*regexp.Regexp{pathPattern}
+	}
+
+	results, err := worktree.Grep(opts)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var matches []GrepMatch
+	for _, result := range results {
+		if len(matches) == limit {
+			return matches, true, nil
+		}
+		matches = append(matches, GrepMatch{Path: result.FileName, Line: result.LineNumber, Text: result.Content})
+	}
+
+	return matches, false, nil
+}
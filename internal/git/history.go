@@ -1,49 +1,74 @@
 package git
 
 import (
+	"fmt"
 	"strings"
+	"time"
 
-	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
 func (r *Repository) GetFileHistory(path string, limit int) ([]*object.Commit, error) {
-	head, err := r.repo.Head()
+	entries, err := r.getFileHistoryEntries(path, limit)
 	if err != nil {
 		return nil, err
 	}
 
-	commitIter, err := r.repo.Log(&git.LogOptions{
-		From:  head.Hash(),
-		Order: git.LogOrderCommitterTime,
-		PathFilter: func(p string) bool {
-			return p == path
-		},
-	})
+	var commits []*object.Commit
+	for _, entry := range entries {
+		commit, err := r.repo.CommitObject(entry.Hash)
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, commit)
+	}
+
+	return commits, nil
+}
+
+func (r *Repository) getFileHistoryEntries(path string, limit int) ([]historyEntry, error) {
+	head, err := r.repo.Head()
 	if err != nil {
 		return nil, err
 	}
 
-	var commits []*object.Commit
-	err = commitIter.ForEach(func(c *object.Commit) error {
-		if len(commits) >= limit {
-			return nil
-		}
-		commits = append(commits, c)
-		return nil
-	})
+	if entries, ok := r.cache.getHistory(head.Hash(), path, limit); ok {
+		return entries, nil
+	}
 
-	return commits, err
+	entries, err := r.followFileHistory(head.Hash(), path, limit, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	r.cache.putHistory(head.Hash(), path, limit, entries)
+	return entries, nil
 }
 
 func (r *Repository) GetEnhancedFileHistory(path string, limit int) ([]CommitInfo, error) {
-	commits, err := r.GetFileHistory(path, limit)
+	entries, err := r.getFileHistoryEntries(path, limit)
 	if err != nil {
 		return nil, err
 	}
 
 	var commitInfos []CommitInfo
-	for _, commit := range commits {
+
+	// Uncommitted changes are the most recent change to the file
+	if r.includeWorktree {
+		if fileDiff, err := r.getWorktreeFileDiff(path); err == nil && len(fileDiff.Hunks) > 0 {
+			info := uncommittedCommitInfo(path)
+			info.Diff = fileDiff.String()
+			commitInfos = append(commitInfos, info)
+		}
+	}
+
+	for _, entry := range entries {
+		commit, err := r.repo.CommitObject(entry.Hash)
+		if err != nil {
+			return nil, err
+		}
+
 		fileIter, err := commit.Files()
 		if err != nil {
 			continue
@@ -56,13 +81,174 @@ func (r *Repository) GetEnhancedFileHistory(path string, limit int) ([]CommitInf
 		})
 
 		commitInfos = append(commitInfos, CommitInfo{
-			Hash:    commit.Hash.String(),
-			Author:  commit.Author.String(),
-			Date:    commit.Author.When,
-			Message: strings.TrimSpace(commit.Message),
-			Changes: changes,
+			Hash:         commit.Hash.String(),
+			Author:       commit.Author.String(),
+			Date:         commit.Author.When,
+			Message:      strings.TrimSpace(commit.Message),
+			Changes:      changes,
+			Path:         entry.Path,
+			PreviousPath: entry.PreviousPath,
 		})
 	}
 
 	return commitInfos, nil
 }
+
+// Resolves a revision (branch, tag, hash, HEAD~n...) to its commit
+func (r *Repository) ResolveCommit(rev string) (*object.Commit, error) {
+	hash, err := r.repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve %q: %w", rev, err)
+	}
+	return r.repo.CommitObject(*hash)
+}
+
+// Lists the commits after good up to and including bad that descend from
+// good, oldest first. Commits merged in from other branches are included.
+func (r *Repository) CommitsBetween(good, bad *object.Commit) ([]*object.Commit, error) {
+	if good.Hash == bad.Hash {
+		return nil, nil
+	}
+
+	ancestor, err := good.IsAncestor(bad)
+	if err != nil {
+		return nil, err
+	}
+	if !ancestor {
+		return nil, fmt.Errorf("%s is not an ancestor of %s", good.Hash.String()[:7], bad.Hash.String()[:7])
+	}
+
+	output, err := r.runGit("rev-list", "--ancestry-path", "--topo-order", "--reverse", good.Hash.String()+".."+bad.Hash.String())
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []*object.Commit
+	for _, hash := range strings.Fields(output) {
+		commit, err := r.repo.CommitObject(plumbing.NewHash(hash))
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, commit)
+	}
+
+	return commits, nil
+}
+
+// Returns the commit details along with its diff against the first parent
+func (r *Repository) GetCommitInfo(commit *object.Commit) (CommitInfo, error) {
+	info := CommitInfo{
+		Hash:    commit.Hash.String(),
+		Author:  commit.Author.String(),
+		Date:    commit.Author.When,
+		Message: strings.TrimSpace(commit.Message),
+	}
+
+	changes, err := commitChanges(commit)
+	if err != nil {
+		return info, err
+	}
+
+	patch, err := changes.Patch()
+	if err != nil {
+		return info, err
+	}
+
+	info.Changes = changedNames(changes)
+	info.Diff = patch.String()
+
+	return info, nil
+}
+
+// Diffs a commit against its first parent
+func commitChanges(commit *object.Commit) (object.Changes, error) {
+	var parentTree *object.Tree
+	if commit.NumParents() > 0 {
+		parent, err := commit.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+		if parentTree, err = parent.Tree(); err != nil {
+			return nil, err
+		}
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	// A nil parent tree treats every file as added in the root commit
+	if parentTree == nil {
+		parentTree = &object.Tree{}
+	}
+
+	return parentTree.Diff(tree)
+}
+
+// Returns the path of each changed file, its old path if it was deleted
+func changedNames(changes object.Changes) []string {
+	var names []string
+	for _, change := range changes {
+		if change.To.Name != "" {
+			names = append(names, change.To.Name)
+		} else {
+			names = append(names, change.From.Name)
+		}
+	}
+	return names
+}
+
+// Returns the hash of the commit checked out at HEAD
+func (r *Repository) HeadCommit() (string, error) {
+	head, err := r.repo.Head()
+	if err != nil {
+		return "", err
+	}
+	return head.Hash().String(), nil
+}
+
+// Returns the name of the branch checked out at HEAD, empty when detached
+func (r *Repository) CurrentBranch() (string, error) {
+	head, err := r.repo.Head()
+	if err != nil {
+		return "", err
+	}
+	if !head.Name().IsBranch() {
+		return "", nil
+	}
+	return head.Name().Short(), nil
+}
+
+// Lists the files that differ between two commits
+func (r *Repository) ChangedFiles(from, to string) ([]string, error) {
+	var trees []*object.Tree
+	for _, rev := range []string{from, to} {
+		commit, err := r.repo.CommitObject(plumbing.NewHash(rev))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read commit %s: %w", rev, err)
+		}
+		tree, err := commit.Tree()
+		if err != nil {
+			return nil, err
+		}
+		trees = append(trees, tree)
+	}
+
+	changes, err := trees[0].Diff(trees[1])
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, change := range changes {
+		if change.From.Name != "" {
+			files = append(files, change.From.Name)
+		}
+		if change.To.Name != "" && change.To.Name != change.From.Name {
+			files = append(files, change.To.Name)
+		}
+	}
+
+	return files, nil
+}
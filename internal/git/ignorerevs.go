@@ -0,0 +1,259 @@
+package git
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/anthonydip/sherlock/internal/logger"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Default file listing commits to ignore in blame, as used by GitHub and GitLab
+const defaultIgnoreRevsFile = ".git-blame-ignore-revs"
+
+// Version of the formatting heuristic, part of the blame cache key
+const formattingHeuristicVersion = "fmt-v1"
+
+var (
+	whitespaceRegex    = regexp.MustCompile(`\s+`)
+	trailingCommaRegex = regexp.MustCompile(`,([)\]}])`)
+)
+
+// Loads the commits listed in blame.ignoreRevsFile, or .git-blame-ignore-revs
+// at the repository root when the option is not set
+func (r *Repository) loadIgnoreRevs() {
+	r.ignoreRevs = make(map[plumbing.Hash]bool)
+	r.formattingOnly = make(map[string]bool)
+
+	path := ""
+	if cfg, err := r.repo.ConfigScoped(config.GlobalScope); err == nil {
+		path = cfg.Raw.Section("blame").Option("ignoreRevsFile")
+	}
+	if path == "" {
+		path = defaultIgnoreRevsFile
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.path, path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		logger.GlobalLogger.Debugf("No blame ignore revs file at %s", path)
+		r.cache.salt = formattingHeuristicVersion
+		return
+	}
+	defer file.Close()
+
+	var revs []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line == "" {
+			continue
+		}
+
+		hash, err := r.repo.ResolveRevision(plumbing.Revision(line))
+		if err != nil {
+			logger.GlobalLogger.Debugf("Ignoring unknown revision %s in %s", line, path)
+			continue
+		}
+
+		r.ignoreRevs[*hash] = true
+		revs = append(revs, hash.String())
+	}
+
+	logger.GlobalLogger.Verbosef("Ignoring %d revision(s) in blame from %s", len(r.ignoreRevs), path)
+
+	// Blame results depend on the ignored revisions, so key the cache by them
+	sort.Strings(revs)
+	sum := sha1.Sum([]byte(formattingHeuristicVersion + strings.Join(revs, ",")))
+	r.cache.salt = hex.EncodeToString(sum[:8])
+}
+
+// Normalizes code so that changes only to whitespace, quote style,
+// semicolons and trailing commas compare equal
+func normalizeFormatting(content string) string {
+	content = whitespaceRegex.ReplaceAllString(content, "")
+	content = strings.NewReplacer("'", `"`, "`", `"`, ";", "").Replace(content)
+	return trailingCommaRegex.ReplaceAllString(content, "$1")
+}
+
+func normalizeLines(content string) string {
+	lines := splitLines(content)
+	for i, line := range lines {
+		lines[i] = normalizeFormatting(line)
+	}
+	return joinLines(lines)
+}
+
+// Reports whether the commit only reformatted the file
+func (r *Repository) isFormattingOnly(commit, parent *object.Commit, path, previous string) bool {
+	key := cacheKey(commit.Hash, path)
+	if result, ok := r.formattingOnly[key]; ok {
+		return result
+	}
+
+	result := false
+	content, err := fileContents(commit, path)
+	if err == nil {
+		if parentContent, err := fileContents(parent, previous); err == nil {
+			result = content != parentContent && normalizeFormatting(content) == normalizeFormatting(parentContent)
+		}
+	}
+
+	if result {
+		logger.GlobalLogger.Debugf("Commit %s only reformatted %s", commit.Hash.String()[:7], path)
+	}
+	r.formattingOnly[key] = result
+	return result
+}
+
+// Reattributes lines blamed on ignored or formatting-only commits to the
+// previous meaningful change of the line
+func (r *Repository) lookThroughIgnoredCommits(result *BlameResult) error {
+	checked := make(map[plumbing.Hash]bool)
+
+	for _, line := range result.Lines {
+		if checked[line.Hash] || line.Uncommitted {
+			continue
+		}
+		checked[line.Hash] = true
+
+		commit, err := r.repo.CommitObject(line.Hash)
+		if err != nil {
+			return err
+		}
+		if commit.NumParents() == 0 {
+			continue
+		}
+
+		parent, err := commit.Parent(0)
+		if err != nil {
+			return err
+		}
+
+		// Follow the file if the commit also renamed it
+		previous := line.Path
+		if _, err := parent.File(previous); err != nil {
+			if previous, err = r.findRename(commit, line.Path); err != nil || previous == "" {
+				continue
+			}
+		}
+
+		if !r.ignoreRevs[commit.Hash] && !r.isFormattingOnly(commit, parent, line.Path, previous) {
+			continue
+		}
+
+		logger.GlobalLogger.Debugf("Looking through %s when blaming %s", commit.Hash.String()[:7], result.Path)
+
+		if err := r.reattributeLines(result, commit, parent, line.Path, previous); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Repository) reattributeLines(result *BlameResult, commit, parent *object.Commit, path, previous string) error {
+	parentBlame, err := r.blameAt(parent.Hash, previous)
+	if err != nil {
+		return err
+	}
+
+	content, err := fileContents(commit, path)
+	if err != nil {
+		return err
+	}
+
+	parentContent, err := fileContents(parent, previous)
+	if err != nil {
+		return err
+	}
+
+	blamedLines := make([]string, 0, len(result.Lines))
+	for _, line := range result.Lines {
+		blamedLines = append(blamedLines, line.Text)
+	}
+
+	// Lines attributed to the commit are unchanged since, so map them to the
+	// commit's version exactly and then to the parent ignoring formatting
+	toCommit := mapLines(content, joinLines(blamedLines))
+	toParent := mapLines(normalizeLines(parentContent), normalizeLines(content))
+	if r.ignoreRevs[commit.Hash] {
+		matchReplacedLines(toParent, len(splitLines(parentContent)))
+	}
+
+	for i := range result.Lines {
+		if result.Lines[i].Hash != commit.Hash || i >= len(toCommit) || toCommit[i] < 0 {
+			continue
+		}
+
+		commitLine := toCommit[i]
+		if commitLine >= len(toParent) || toParent[commitLine] < 0 || toParent[commitLine] >= len(parentBlame.Lines) {
+			continue
+		}
+
+		attributed := parentBlame.Lines[toParent[commitLine]]
+		attributed.Text = result.Lines[i].Text
+		result.Lines[i] = attributed
+	}
+
+	return nil
+}
+
+// Maps lines of replaced hunks one-to-one when both sides have the same
+// length, so lines edited by an ignored commit keep their earlier author
+func matchReplacedLines(mapping []int, oldCount int) {
+	for i := 0; i < len(mapping); {
+		if mapping[i] >= 0 {
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(mapping) && mapping[i] < 0 {
+			i++
+		}
+
+		oldStart, oldEnd := 0, oldCount
+		if start > 0 {
+			oldStart = mapping[start-1] + 1
+		}
+		if i < len(mapping) {
+			oldEnd = mapping[i]
+		}
+
+		if oldEnd-oldStart != i-start {
+			continue
+		}
+		for j := start; j < i; j++ {
+			mapping[j] = oldStart + j - start
+		}
+	}
+}
+
+func fileContents(commit *object.Commit, path string) (string, error) {
+	file, err := commit.File(path)
+	if err != nil {
+		return "", err
+	}
+	return file.Contents()
+}
+
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,92 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/anthonydip/sherlock/internal/logger"
+)
+
+// Checks that a unified diff applies cleanly to the working tree
+func (r *Repository) CheckPatch(patch string) error {
+	return CheckPatchIn(r.path, patch)
+}
+
+// Applies a unified diff to the working tree
+func (r *Repository) ApplyPatch(patch string) error {
+	return ApplyPatchIn(r.path, patch)
+}
+
+// Checks that a unified diff applies cleanly to the checkout in dir
+func CheckPatchIn(dir string, patch string) error {
+	_, err := runGitIn(dir, patch, "apply", "--check", "--recount", "--whitespace=nowarn", "-")
+	return err
+}
+
+// Applies a unified diff to the checkout in dir, such as a temporary worktree.
+// Hunk line counts are recomputed, as generated patches often get them wrong.
+func ApplyPatchIn(dir string, patch string) error {
+	_, err := runGitIn(dir, patch, "apply", "--recount", "--whitespace=nowarn", "-")
+	return err
+}
+
+// Reverts a diff applied with ApplyPatchIn
+func RevertPatchIn(dir string, patch string) error {
+	_, err := runGitIn(dir, patch, "apply", "--reverse", "--recount", "--whitespace=nowarn", "-")
+	return err
+}
+
+// Commits a patch onto a new branch created from HEAD. The patch is applied
+// in a temporary worktree, so the user's checkout is left untouched.
+// Returns the hash of the new commit.
+func (r *Repository) CommitPatchToBranch(patch string, branch string, message string) (string, error) {
+	if _, err := r.runGit("rev-parse", "--verify", "--quiet", "refs/heads/"+branch); err == nil {
+		return "", fmt.Errorf("branch '%s' already exists", branch)
+	}
+
+	dir, err := os.MkdirTemp("", "sherlock-fix-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := r.runGit("worktree", "add", "-b", branch, dir, "HEAD"); err != nil {
+		return "", err
+	}
+
+	hash, err := commitPatchIn(dir, patch, message)
+
+	if _, removeErr := r.runGit("worktree", "remove", "--force", dir); removeErr != nil {
+		logger.GlobalLogger.Debugf("Failed to remove worktree %s: %v", dir, removeErr)
+		r.runGit("worktree", "prune")
+	}
+
+	// Leave no half-created branch behind
+	if err != nil {
+		if _, deleteErr := r.runGit("branch", "-D", branch); deleteErr != nil {
+			logger.GlobalLogger.Debugf("Failed to delete branch %s: %v", branch, deleteErr)
+		}
+		return "", err
+	}
+
+	return hash, nil
+}
+
+func commitPatchIn(dir string, patch string, message string) (string, error) {
+	if err := ApplyPatchIn(dir, patch); err != nil {
+		return "", err
+	}
+	if _, err := runGitIn(dir, "", "add", "--all"); err != nil {
+		return "", err
+	}
+	if _, err := runGitIn(dir, "", "commit", "--quiet", "-m", message); err != nil {
+		return "", err
+	}
+
+	hash, err := runGitIn(dir, "", "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(hash), nil
+}
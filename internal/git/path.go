@@ -3,21 +3,12 @@ package git
 import (
 	"os"
 	"path/filepath"
-	"strings"
-)
 
-func extractFilePath(location string) string {
-	if len(location) >= 2 && location[1] == ':' {
-		if lastColon := strings.LastIndex(location, ":"); lastColon > 1 {
-			return location[:lastColon]
-		}
-		return location
-	}
-	return strings.Split(location, ":")[0]
-}
+	"github.com/anthonydip/sherlock/internal/source"
+)
 
 func NormalizeTestPath(failureLocation string, repoPath string) (string, error) {
-	path := extractFilePath(failureLocation)
+	path := source.LocationPath(failureLocation)
 	path = filepath.Clean(path)
 
 	// Try to resolve path if already relative
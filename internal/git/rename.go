@@ -0,0 +1,194 @@
+package git
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/anthonydip/sherlock/internal/logger"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// A commit in a file's history along with the file's path at that commit
+type historyEntry struct {
+	Hash         plumbing.Hash `json:"hash"`
+	Path         string        `json:"path"`
+	PreviousPath string        `json:"previous_path,omitempty"`
+}
+
+// Walks the log from the given commit, following the file across renames
+// and copies. Stops at commits older than since, unless it is zero.
+func (r *Repository) followFileHistory(from plumbing.Hash, path string, limit int, since time.Time) ([]historyEntry, error) {
+	commitIter, err := r.repo.Log(&git.LogOptions{
+		From:  from,
+		Order: git.LogOrderCommitterTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []historyEntry
+	current := path
+
+	err = commitIter.ForEach(func(c *object.Commit) error {
+		if len(entries) >= limit || (!since.IsZero() && c.Committer.When.Before(since)) {
+			return storer.ErrStop
+		}
+
+		file, err := c.File(current)
+		if err == object.ErrFileNotFound {
+			// Commit predates the file under its current name
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if c.NumParents() == 0 {
+			entries = append(entries, historyEntry{Hash: c.Hash, Path: current})
+			return storer.ErrStop
+		}
+
+		// Skip commits where the file matches any parent, as git log does
+		existsInParent := false
+		unchanged := false
+		err = c.Parents().ForEach(func(parent *object.Commit) error {
+			parentFile, err := parent.File(current)
+			if err == object.ErrFileNotFound {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			existsInParent = true
+			if parentFile.Hash == file.Hash {
+				unchanged = true
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if unchanged {
+			return nil
+		}
+
+		if existsInParent {
+			entries = append(entries, historyEntry{Hash: c.Hash, Path: current})
+			return nil
+		}
+
+		// File appeared under this name, check whether it was moved or copied
+		previous, err := r.findRename(c, current)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, historyEntry{Hash: c.Hash, Path: current, PreviousPath: previous})
+		if previous == "" {
+			// File was created here, history ends
+			return storer.ErrStop
+		}
+
+		logger.GlobalLogger.Debugf("Following %s across rename from %s in %s", current, previous, c.Hash.String()[:7])
+		current = previous
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Returns the path the file was renamed or copied from in the commit, or an
+// empty string if the file was newly created
+func (r *Repository) findRename(commit *object.Commit, path string) (string, error) {
+	if commit.NumParents() == 0 {
+		return "", nil
+	}
+
+	parent, err := commit.Parent(0)
+	if err != nil {
+		return "", err
+	}
+
+	parentTree, err := parent.Tree()
+	if err != nil {
+		return "", err
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return "", err
+	}
+
+	opts := *object.DefaultDiffTreeOptions
+	opts.DetectRenames = true
+	opts.OnlyExactRenames = false
+
+	changes, err := object.DiffTreeWithOptions(context.Background(), parentTree, tree, &opts)
+	if err != nil {
+		return "", err
+	}
+
+	for _, change := range changes {
+		if change.To.Name == path && change.From.Name != "" && change.From.Name != path {
+			return change.From.Name, nil
+		}
+	}
+
+	return "", nil
+}
+
+// Sets the path each blamed line had in its commit. go-git follows renames
+// when blaming but only reports the current path.
+func (r *Repository) resolveBlamePaths(result *BlameResult, from plumbing.Hash) error {
+	// Whether each blamed commit has the file under its current path
+	found := make(map[plumbing.Hash]bool)
+	var oldest time.Time
+
+	for _, line := range result.Lines {
+		if _, ok := found[line.Hash]; ok {
+			continue
+		}
+
+		commit, err := r.repo.CommitObject(line.Hash)
+		if err != nil {
+			return err
+		}
+		_, err = commit.File(line.Path)
+		found[line.Hash] = err == nil
+
+		if err != nil && (oldest.IsZero() || commit.Committer.When.Before(oldest)) {
+			oldest = commit.Committer.When
+		}
+	}
+
+	if oldest.IsZero() {
+		return nil
+	}
+
+	// Walk the renames once, back to the oldest commit with another path
+	entries, err := r.followFileHistory(from, result.Path, math.MaxInt, oldest)
+	if err != nil {
+		return err
+	}
+
+	paths := make(map[plumbing.Hash]string)
+	for _, entry := range entries {
+		paths[entry.Hash] = entry.Path
+	}
+
+	for i, line := range result.Lines {
+		if found[line.Hash] {
+			continue
+		}
+		if p, ok := paths[line.Hash]; ok {
+			result.Lines[i].Path = p
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBlameFollowsRename(t *testing.T) {
+	dir := newFixtureRepo(t, map[string]string{"src/old.js": "one\ntwo\n"})
+	initial := runFixtureGit(t, dir, "rev-parse", "HEAD")
+
+	runFixtureGit(t, dir, "mv", "src/old.js", "src/new.js")
+	runFixtureGit(t, dir, "commit", "--quiet", "-m", "rename")
+	if err := os.WriteFile(filepath.Join(dir, "src", "new.js"), []byte("one\ntwo\nthree\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runFixtureGit(t, dir, "commit", "--quiet", "-am", "add line")
+	head := runFixtureGit(t, dir, "rev-parse", "HEAD")
+
+	repo := openFixture(t, filepath.Join(dir, "src", "new.js"), dir, head)
+	blame, err := repo.GetBlame("src/new.js")
+	if err != nil {
+		t.Fatalf("GetBlame: %v", err)
+	}
+
+	want := []struct{ hash, path string }{
+		{initial, "src/old.js"},
+		{initial, "src/old.js"},
+		{head, "src/new.js"},
+	}
+	if len(blame.Lines) != len(want) {
+		t.Fatalf("GetBlame returned %d lines, want %d", len(blame.Lines), len(want))
+	}
+	for i, line := range blame.Lines {
+		if line.Hash.String() != want[i].hash || line.Path != want[i].path {
+			t.Errorf("line %d = %s %s, want %s %s", i+1, line.Hash.String()[:7], line.Path, want[i].hash[:7], want[i].path)
+		}
+	}
+}
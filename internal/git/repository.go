@@ -12,14 +12,20 @@ import (
 	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
-// Open Git repository at the given path
+// Open Git repository containing the given file or directory
 func OpenRepository(path string, depth int) (*Repository, error) {
 	absPath, err := filepath.Abs(path)
 	if err != nil {
 		return nil, err
 	}
 
-	repoPath, err := findRepositoryRoot(filepath.Dir(absPath), depth)
+	// Start the search from the file's directory, or the path itself if it is a directory
+	startPath := filepath.Dir(absPath)
+	if fi, err := os.Stat(absPath); err == nil && fi.IsDir() {
+		startPath = absPath
+	}
+
+	repoPath, err := findRepositoryRoot(startPath, depth)
 	if err != nil {
 		return nil, err
 	}
@@ -33,8 +39,15 @@ func OpenRepository(path string, depth int) (*Repository, error) {
 
 	logger.GlobalLogger.Verbosef("Opening Git repository at: %s", repoPath)
 
-	// Open with go-git
-	repo, err := git.PlainOpen(repoPath)
+	return openAt(repoPath)
+}
+
+func openAt(repoPath string) (*Repository, error) {
+	// Resolve the common dir so linked worktrees (git worktree add) share
+	// the main repository's objects and refs
+	repo, err := git.PlainOpenWithOptions(repoPath, &git.PlainOpenOptions{
+		EnableDotGitCommonDir: true,
+	})
 	if err != nil {
 		if errors.Is(err, git.ErrRepositoryNotExists) {
 			return nil, ErrNotAGitRepository
@@ -42,10 +55,64 @@ func OpenRepository(path string, depth int) (*Repository, error) {
 		return nil, err
 	}
 
-	return &Repository{
-		path: repoPath,
-		repo: repo,
-	}, nil
+	r := &Repository{
+		path:       repoPath,
+		repo:       repo,
+		cache:      newRepoCache(""),
+		submodules: make(map[string]*Repository),
+	}
+	r.loadIgnoreRevs()
+	r.UseDiskCache(true)
+
+	return r, nil
+}
+
+// Returns the repository tracking the given repo-relative path along with the
+// path relative to it. Paths inside a submodule resolve to the submodule's
+// repository, since the superproject only records the submodule's commit.
+func (r *Repository) RepositoryFor(relPath string) (*Repository, string, error) {
+	relPath = filepath.FromSlash(relPath)
+
+	// Search from the file's directory upwards so nested submodules win
+	for dir := filepath.Dir(relPath); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(r.path, dir, ".git")); err != nil {
+			continue
+		}
+
+		sub, err := r.openSubmodule(dir)
+		if err != nil {
+			return nil, "", err
+		}
+
+		subPath, err := filepath.Rel(dir, relPath)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return sub, filepath.ToSlash(subPath), nil
+	}
+
+	return r, filepath.ToSlash(relPath), nil
+}
+
+func (r *Repository) openSubmodule(dir string) (*Repository, error) {
+	if sub, ok := r.submodules[dir]; ok {
+		return sub, nil
+	}
+
+	logger.GlobalLogger.Verbosef("Opening submodule repository at: %s", dir)
+
+	sub, err := openAt(filepath.Join(r.path, dir))
+	if err != nil {
+		return nil, fmt.Errorf("submodule %s: %w", dir, err)
+	}
+
+	// Submodules follow the superproject's settings
+	sub.includeWorktree = r.includeWorktree
+	sub.UseDiskCache(r.cache.dir != "")
+
+	r.submodules[dir] = sub
+	return sub, nil
 }
 
 // Searches parent directories to find a Git repository given depth
@@ -65,7 +132,7 @@ func findRepositoryRoot(startPath string, depth int) (string, error) {
 				return current, nil
 			}
 
-			// Handle git submodules
+			// Handle git submodules and linked worktrees
 			if content, err := os.ReadFile(gitPath); err == nil {
 				if strings.HasPrefix(string(content), "gitdir: ") {
 					return current, nil
@@ -73,6 +140,11 @@ func findRepositoryRoot(startPath string, depth int) (string, error) {
 			}
 		}
 
+		// Bare repositories keep the Git directory layout at their root
+		if isBareRepository(current) {
+			return current, nil
+		}
+
 		// Move up one directory
 		parent := filepath.Dir(current)
 		if parent == current {
@@ -84,12 +156,34 @@ func findRepositoryRoot(startPath string, depth int) (string, error) {
 	return "", ErrNotAGitRepository
 }
 
+// Reports whether dir is a bare repository, such as one made by git clone
+// --bare. The .git directory of a checkout has the same layout but is found
+// through its parent instead.
+func isBareRepository(dir string) bool {
+	if filepath.Base(dir) == ".git" {
+		return false
+	}
+
+	if fi, err := os.Stat(filepath.Join(dir, "HEAD")); err != nil || fi.IsDir() {
+		return false
+	}
+	for _, name := range []string{"objects", "refs"} {
+		if fi, err := os.Stat(filepath.Join(dir, name)); err != nil || !fi.IsDir() {
+			return false
+		}
+	}
+	return true
+}
+
 // Check for uncomitted changes in working tree
 func (r *Repository) IsDirty() (bool, error) {
 	logger.GlobalLogger.Verbosef("Checking repository status at: %s", r.path)
 
 	w, err := r.repo.Worktree()
-	if err != nil {
+	if errors.Is(err, git.ErrIsBareRepository) {
+		logger.GlobalLogger.Verbosef("Repository is bare, no working tree to check")
+		return false, nil
+	} else if err != nil {
 		return false, fmt.Errorf("failed to get worktree: %w", err)
 	}
 
@@ -108,8 +202,8 @@ func (r *Repository) IsDirty() (bool, error) {
 		logger.GlobalLogger.Debugf("File status: %s (Worktree: %s, Staging: %s)",
 			path, wtCode, stCode)
 
-		// Skip files that are only untracked
-		if wtCode == "?" && stCode == " " {
+		// Skip files that are only untracked (go-git may report them in both columns)
+		if wtCode == "?" && (stCode == " " || stCode == "?") {
 			logger.GlobalLogger.Debugf("Ignoring untracked file: %s", path)
 			continue
 		}
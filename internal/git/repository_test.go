@@ -0,0 +1,191 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anthonydip/sherlock/internal/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.GlobalLogger = logger.New(false, false, false)
+	os.Exit(m.Run())
+}
+
+// Runs git in dir with a fixed identity and no user or system configuration
+func runFixtureGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", append([]string{"-c", "protocol.file.allow=always"}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_CONFIG_GLOBAL=/dev/null",
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_AUTHOR_NAME=Fixture",
+		"GIT_AUTHOR_EMAIL=fixture@example.com",
+		"GIT_COMMITTER_NAME=Fixture",
+		"GIT_COMMITTER_EMAIL=fixture@example.com",
+	)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// Creates a repository in a temporary directory with one commit of the files
+func newFixtureRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runFixtureGit(t, dir, "init", "--quiet", "--initial-branch=main")
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runFixtureGit(t, dir, "add", "--all")
+	runFixtureGit(t, dir, "commit", "--quiet", "-m", "initial")
+
+	return dir
+}
+
+// Opens the repository of a file and checks its root and HEAD commit
+func openFixture(t *testing.T, path string, wantRoot string, wantHead string) *Repository {
+	t.Helper()
+
+	repo, err := OpenRepository(path, 5)
+	if err != nil {
+		t.Fatalf("OpenRepository(%s): %v", path, err)
+	}
+	if repo.Path() != wantRoot {
+		t.Errorf("OpenRepository(%s) root = %s, want %s", path, repo.Path(), wantRoot)
+	}
+
+	head, err := repo.HeadCommit()
+	if err != nil {
+		t.Fatalf("HeadCommit: %v", err)
+	}
+	if head != wantHead {
+		t.Errorf("HeadCommit = %s, want %s", head, wantHead)
+	}
+
+	return repo
+}
+
+// Checks that a path resolves to the expected repository and relative path
+func checkRepositoryFor(t *testing.T, repo *Repository, relPath string, wantRoot string, wantPath string) *Repository {
+	t.Helper()
+
+	owner, ownerPath, err := repo.RepositoryFor(relPath)
+	if err != nil {
+		t.Fatalf("RepositoryFor(%s): %v", relPath, err)
+	}
+	if owner.Path() != wantRoot || ownerPath != wantPath {
+		t.Errorf("RepositoryFor(%s) = %s, %s, want %s, %s", relPath, owner.Path(), ownerPath, wantRoot, wantPath)
+	}
+
+	return owner
+}
+
+func TestOpenPlainRepository(t *testing.T) {
+	dir := newFixtureRepo(t, map[string]string{"src/app.test.js": "test('works', () => {})\n"})
+	head := runFixtureGit(t, dir, "rev-parse", "HEAD")
+
+	repo := openFixture(t, filepath.Join(dir, "src", "app.test.js"), dir, head)
+	checkRepositoryFor(t, repo, "src/app.test.js", dir, "src/app.test.js")
+
+	if dirty, err := repo.IsDirty(); err != nil || dirty {
+		t.Errorf("IsDirty = %t, %v, want clean", dirty, err)
+	}
+}
+
+func TestOpenLinkedWorktree(t *testing.T) {
+	dir := newFixtureRepo(t, map[string]string{"src/app.test.js": "test('works', () => {})\n"})
+
+	// Commit on a branch only checked out in the linked worktree, so reading
+	// it requires the main repository's objects and refs
+	worktree := filepath.Join(filepath.Dir(dir), filepath.Base(dir)+"-worktree")
+	t.Cleanup(func() { os.RemoveAll(worktree) })
+	runFixtureGit(t, dir, "worktree", "add", "--quiet", "-b", "feature", worktree)
+	if err := os.WriteFile(filepath.Join(worktree, "src", "app.test.js"), []byte("test('changed', () => {})\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runFixtureGit(t, worktree, "commit", "--quiet", "-am", "change")
+	head := runFixtureGit(t, worktree, "rev-parse", "HEAD")
+
+	repo := openFixture(t, filepath.Join(worktree, "src", "app.test.js"), worktree, head)
+	checkRepositoryFor(t, repo, "src/app.test.js", worktree, "src/app.test.js")
+
+	history, err := repo.GetFileHistory("src/app.test.js", 5)
+	if err != nil {
+		t.Fatalf("GetFileHistory: %v", err)
+	}
+	if len(history) != 2 {
+		t.Errorf("GetFileHistory returned %d commits, want 2", len(history))
+	}
+}
+
+func TestOpenSubmodule(t *testing.T) {
+	library := newFixtureRepo(t, map[string]string{"index.js": "module.exports = 1\n"})
+	libraryHead := runFixtureGit(t, library, "rev-parse", "HEAD")
+
+	dir := newFixtureRepo(t, map[string]string{"src/app.test.js": "require('../lib')\n"})
+	runFixtureGit(t, dir, "submodule", "add", "--quiet", library, "lib")
+	runFixtureGit(t, dir, "commit", "--quiet", "-m", "add submodule")
+	head := runFixtureGit(t, dir, "rev-parse", "HEAD")
+	submodule := filepath.Join(dir, "lib")
+
+	// Files in the superproject belong to it, files in the submodule to the
+	// submodule's own repository
+	repo := openFixture(t, filepath.Join(dir, "src", "app.test.js"), dir, head)
+	checkRepositoryFor(t, repo, "src/app.test.js", dir, "src/app.test.js")
+	owner := checkRepositoryFor(t, repo, "lib/index.js", submodule, "index.js")
+
+	ownerHead, err := owner.HeadCommit()
+	if err != nil || ownerHead != libraryHead {
+		t.Errorf("submodule HeadCommit = %s, %v, want %s", ownerHead, err, libraryHead)
+	}
+
+	// Opening a submodule file directly finds the submodule
+	sub := openFixture(t, filepath.Join(submodule, "index.js"), submodule, libraryHead)
+	checkRepositoryFor(t, sub, "index.js", submodule, "index.js")
+}
+
+func TestOpenBareRepository(t *testing.T) {
+	source := newFixtureRepo(t, map[string]string{"src/app.test.js": "test('works', () => {})\n"})
+	head := runFixtureGit(t, source, "rev-parse", "HEAD")
+
+	bare := filepath.Join(filepath.Dir(source), filepath.Base(source)+".git")
+	t.Cleanup(func() { os.RemoveAll(bare) })
+	runFixtureGit(t, source, "clone", "--quiet", "--bare", source, bare)
+
+	repo := openFixture(t, bare, bare, head)
+	checkRepositoryFor(t, repo, "src/app.test.js", bare, "src/app.test.js")
+
+	// Files are read from HEAD as there is no working tree
+	if dirty, err := repo.IsDirty(); err != nil || dirty {
+		t.Errorf("IsDirty = %t, %v, want clean", dirty, err)
+	}
+	history, err := repo.GetFileHistory("src/app.test.js", 5)
+	if err != nil || len(history) != 1 {
+		t.Errorf("GetFileHistory = %d commits, %v, want 1", len(history), err)
+	}
+
+	// The .git directory of a checkout is not mistaken for a bare repository
+	if isBareRepository(filepath.Join(source, ".git")) {
+		t.Errorf("isBareRepository(.git) = true, want false")
+	}
+}
@@ -4,11 +4,26 @@ import (
 	"time"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 )
 
 type Repository struct {
 	path string
 	repo *git.Repository
+
+	// Attribute uncommitted working tree changes instead of only HEAD
+	includeWorktree bool
+
+	cache *repoCache
+
+	// Commits skipped by blame, from .git-blame-ignore-revs
+	ignoreRevs map[plumbing.Hash]bool
+
+	// Formatting-only decisions by commit and path
+	formattingOnly map[string]bool
+
+	// Opened submodule repositories by repo-relative path
+	submodules map[string]*Repository
 }
 
 type CommitInfo struct {
@@ -18,4 +33,47 @@ type CommitInfo struct {
 	Message string
 	Changes []string
 	Diff    string
+
+	// Path of the file at this commit, and before the commit if it renamed the file
+	Path         string
+	PreviousPath string
+
+	Uncommitted bool
+}
+
+// Line-by-line attribution of a file
+type BlameResult struct {
+	Path  string
+	Lines []BlameLine
+}
+
+type BlameLine struct {
+	Hash       plumbing.Hash
+	Author     string
+	AuthorName string
+	Date       time.Time
+	Text       string
+
+	// Path of the file in the attributed commit, which differs from the
+	// blamed path if the file was renamed since
+	Path string
+
+	// Line only exists in the working tree
+	Uncommitted bool
+}
+
+// Changes to a single file between two versions
+type FileDiff struct {
+	Path    string
+	OldPath string
+	Hunks   []Hunk
+}
+
+// A contiguous block of changed lines with surrounding context
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []string // Prefixed with ' ', '+' or '-'
 }
@@ -0,0 +1,132 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/anthonydip/sherlock/internal/logger"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Enables attribution of uncommitted working tree changes
+func (r *Repository) IncludeWorktree(include bool) {
+	r.includeWorktree = include
+}
+
+// Computes the uncommitted changes of the working tree against HEAD
+func (r *Repository) GetWorktreeDiff() ([]FileDiff, error) {
+	w, err := r.repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := w.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for path, fileStatus := range status {
+		if fileStatus.Worktree == git.Unmodified && fileStatus.Staging == git.Unmodified {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var diffs []FileDiff
+	for _, path := range paths {
+		fileDiff, err := r.getWorktreeFileDiff(path)
+		if err != nil {
+			logger.GlobalLogger.Debugf("Skipping %s in worktree diff: %v", path, err)
+			continue
+		}
+		if len(fileDiff.Hunks) > 0 {
+			diffs = append(diffs, fileDiff)
+		}
+	}
+
+	logger.GlobalLogger.Debugf("Worktree diff touches %d file(s)", len(diffs))
+	return diffs, nil
+}
+
+func (r *Repository) getWorktreeFileDiff(path string) (FileDiff, error) {
+	headContent, worktreeContent, err := r.worktreeFileVersions(path)
+	if err != nil {
+		return FileDiff{}, err
+	}
+
+	return FileDiff{
+		Path:    path,
+		OldPath: path,
+		Hunks:   diffContents(headContent, worktreeContent),
+	}, nil
+}
+
+// Returns the HEAD and working tree content of a file with normalized line
+// endings, using empty content for a side where the file does not exist
+func (r *Repository) worktreeFileVersions(path string) (string, string, error) {
+	headContent, err := r.headFileContent(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	worktreeContent := ""
+	content, err := os.ReadFile(filepath.Join(r.path, path))
+	if err == nil {
+		worktreeContent = string(content)
+	} else if !os.IsNotExist(err) {
+		return "", "", err
+	}
+
+	return strings.ReplaceAll(headContent, "\r\n", "\n"), strings.ReplaceAll(worktreeContent, "\r\n", "\n"), nil
+}
+
+func (r *Repository) headFileContent(path string) (string, error) {
+	head, err := r.repo.Head()
+	if err != nil {
+		return "", err
+	}
+
+	commit, err := r.repo.CommitObject(head.Hash())
+	if err != nil {
+		return "", err
+	}
+
+	file, err := commit.File(path)
+	if err == object.ErrFileNotFound {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	return file.Contents()
+}
+
+// Checks out the given commit into a temporary linked worktree, leaving the
+// user's checkout untouched. The returned function removes the worktree.
+func (r *Repository) AddTempWorktree(hash string) (string, func(), error) {
+	dir, err := os.MkdirTemp("", "sherlock-worktree-")
+	if err != nil {
+		return "", nil, err
+	}
+
+	if _, err := r.runGit("worktree", "add", "--detach", "--force", dir, hash); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+
+	cleanup := func() {
+		if _, err := r.runGit("worktree", "remove", "--force", dir); err != nil {
+			logger.GlobalLogger.Debugf("Failed to remove worktree %s: %v", dir, err)
+			os.RemoveAll(dir)
+			r.runGit("worktree", "prune")
+		}
+	}
+
+	logger.GlobalLogger.Debugf("Created temporary worktree for %s at %s", hash[:7], dir)
+	return dir, cleanup, nil
+}
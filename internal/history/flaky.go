@@ -0,0 +1,139 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Lists the files that differ between two commits
+type ChangeFunc func(from, to string) ([]string, error)
+
+// Intermittent failures of a test across the recorded runs
+type Flakiness struct {
+	File     string `json:"file"`
+	Test     string `json:"test"`
+	Runs     int    `json:"runs"`
+	Failures int    `json:"failures"`
+	Commits  int    `json:"commits"`
+
+	// Outcome changes between runs with no related code change
+	Flips int `json:"flips"`
+}
+
+// Identifies the test as Record.Key does
+func (f Flakiness) Key() string {
+	return f.File + "::" + f.Test
+}
+
+func (f Flakiness) Summary() string {
+	commits := "commits"
+	if f.Commits == 1 {
+		commits = "commit"
+	}
+	return fmt.Sprintf("failed %d/%d runs across %d %s", f.Failures, f.Runs, f.Commits, commits)
+}
+
+// Finds tests that both passed and failed where the code they depend on did
+// not change: on the same commit, or across commits that did not touch the
+// test file or any file on its failing stack traces. Without changed, only
+// outcome changes on the same commit count.
+func Detect(records []Record, changed ChangeFunc) []Flakiness {
+	byTest := make(map[string][]Record)
+	var keys []string
+	for _, record := range records {
+		key := record.Key()
+		if _, ok := byTest[key]; !ok {
+			keys = append(keys, key)
+		}
+		byTest[key] = append(byTest[key], record)
+	}
+
+	diffs := make(map[string][]string)
+	changedFiles := func(from, to string) ([]string, bool) {
+		key := from + ".." + to
+		if files, ok := diffs[key]; ok {
+			return files, files != nil
+		}
+
+		files, err := changed(from, to)
+		if err != nil {
+			diffs[key] = nil
+			return nil, false
+		}
+		if files == nil {
+			files = []string{}
+		}
+		diffs[key] = files
+		return files, true
+	}
+
+	var flaky []Flakiness
+	for _, key := range keys {
+		runs := byTest[key]
+		sort.SliceStable(runs, func(i, j int) bool { return runs[i].Time.Before(runs[j].Time) })
+
+		stats := Flakiness{File: runs[0].File, Test: runs[0].Test, Runs: len(runs)}
+
+		related := map[string]bool{stats.File: true}
+		commits := make(map[string]bool)
+		for _, run := range runs {
+			if !run.Passed {
+				stats.Failures++
+				for _, file := range run.Files {
+					related[file] = true
+				}
+			}
+			if run.Commit != "" {
+				commits[run.Commit] = true
+			}
+		}
+		stats.Commits = len(commits)
+
+		if stats.Failures == 0 || stats.Failures == stats.Runs {
+			continue
+		}
+
+		for i := 1; i < len(runs); i++ {
+			previous, current := runs[i-1], runs[i]
+
+			// Uncommitted changes leave the tested code unknown
+			if previous.Passed == current.Passed || previous.Dirty || current.Dirty ||
+				previous.Commit == "" || current.Commit == "" {
+				continue
+			}
+
+			if previous.Commit == current.Commit {
+				stats.Flips++
+				continue
+			}
+
+			if changed == nil {
+				continue
+			}
+			files, ok := changedFiles(previous.Commit, current.Commit)
+			if ok && !touchesAny(files, related) {
+				stats.Flips++
+			}
+		}
+
+		if stats.Flips > 0 {
+			flaky = append(flaky, stats)
+		}
+	}
+
+	// Most frequently failing first
+	sort.SliceStable(flaky, func(i, j int) bool {
+		return flaky[i].Failures*flaky[j].Runs > flaky[j].Failures*flaky[i].Runs
+	})
+
+	return flaky
+}
+
+func touchesAny(files []string, related map[string]bool) bool {
+	for _, file := range files {
+		if related[file] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/anthonydip/sherlock/internal/logger"
+)
+
+// Outcome of one test in one analyzed run
+type Record struct {
+	Run    string    `json:"run"` // Shared by the records of one run
+	Time   time.Time `json:"time"`
+	Commit string    `json:"commit,omitempty"`
+
+	// Run had uncommitted changes, so the commit does not describe the code
+	Dirty bool `json:"dirty,omitempty"`
+
+	File   string `json:"file"`
+	Test   string `json:"test"`
+	Passed bool   `json:"passed"`
+
+	// Failure signature and repository paths on the stack trace
+	Signature string   `json:"signature,omitempty"`
+	Files     []string `json:"files,omitempty"`
+}
+
+// Identifies a test across runs
+func (r Record) Key() string {
+	return r.File + "::" + r.Test
+}
+
+// Append-only JSON Lines file of past results
+type Store struct {
+	path string
+}
+
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Returns the history file of a project outside Git, under the user cache directory
+func DefaultPath(root string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha1.Sum([]byte(root))
+	return filepath.Join(dir, "sherlock", "history", hex.EncodeToString(sum[:6])+".jsonl"), nil
+}
+
+// Returns an ID for a new run, ordered by start time
+func NewRunID() string {
+	return time.Now().UTC().Format("20060102T150405.000000000")
+}
+
+// Returns the ID of a run from its test output and commit, so analyzing the
+// same output again is recognized as an already recorded run
+func RunID(output io.Reader, commit string) (string, error) {
+	hash := sha1.New()
+	if _, err := io.Copy(hash, output); err != nil {
+		return "", err
+	}
+	hash.Write([]byte("\x00" + commit))
+
+	return hex.EncodeToString(hash.Sum(nil)[:10]), nil
+}
+
+// Reports whether the records include a run
+func Recorded(records []Record, run string) bool {
+	for _, record := range records {
+		if record.Run == run {
+			return true
+		}
+	}
+	return false
+}
+
+// Reads every record, skipping lines that cannot be decoded
+func (s *Store) Load() ([]Record, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			logger.GlobalLogger.Debugf("Skipping malformed history line %d: %v", line, err)
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return records, scanner.Err()
+}
+
+// Adds the records of a run to the end of the file
+func (s *Store) Append(records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	// A single write keeps concurrent runs from interleaving their lines
+	if _, err := file.Write(buffer.Bytes()); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
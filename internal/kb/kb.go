@@ -0,0 +1,214 @@
+package kb
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/anthonydip/sherlock/internal/logger"
+	"github.com/anthonydip/sherlock/internal/parsers"
+	"gopkg.in/yaml.v3"
+)
+
+// Default location of the knowledge base, relative to the repository root
+const DefaultDir = ".sherlock/known-issues"
+
+// A known failure with a canned explanation, stored as one YAML file
+type Entry struct {
+	ID          string   `yaml:"id"`
+	Title       string   `yaml:"title"`
+	Pattern     string   `yaml:"pattern"` // Regex matched against the error message
+	Explanation string   `yaml:"explanation"`
+	Fixes       []string `yaml:"fixes"`
+
+	regex *regexp.Regexp
+}
+
+type KnowledgeBase struct {
+	Dir     string
+	Entries []Entry
+}
+
+type Match struct {
+	Entry *Entry
+
+	// The pattern matched the error itself rather than only the full
+	// message, so the entry can replace the AI analysis
+	Confident bool
+}
+
+// Loads all entries of a knowledge base directory. A missing directory is an
+// empty knowledge base.
+func Load(dir string) (*KnowledgeBase, error) {
+	kb := &KnowledgeBase{Dir: dir}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			logger.GlobalLogger.Debugf("No knowledge base at %s", dir)
+			return kb, nil
+		}
+		return nil, err
+	}
+
+	for _, file := range files {
+		ext := filepath.Ext(file.Name())
+		if file.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		path := filepath.Join(dir, file.Name())
+		entry, err := loadEntry(path)
+		if err != nil {
+			logger.GlobalLogger.Warnf("Skipping invalid known issue %s: %v", path, err)
+			continue
+		}
+
+		kb.Entries = append(kb.Entries, *entry)
+	}
+
+	logger.GlobalLogger.Verbosef("Loaded %d known issue(s) from %s", len(kb.Entries), dir)
+	return kb, nil
+}
+
+func loadEntry(path string) (*Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry Entry
+	if err := yaml.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+
+	if entry.Pattern == "" {
+		return nil, fmt.Errorf("missing pattern")
+	}
+	if entry.ID == "" {
+		entry.ID = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	entry.regex, err = regexp.Compile(entry.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	return &entry, nil
+}
+
+// Finds the first entry matching a failure, preferring matches on the error
+func (kb *KnowledgeBase) Match(failure parsers.TestFailure) *Match {
+	if kb == nil {
+		return nil
+	}
+
+	for i := range kb.Entries {
+		if kb.Entries[i].regex.MatchString(failure.Error) {
+			return &Match{Entry: &kb.Entries[i], Confident: true}
+		}
+	}
+
+	for i := range kb.Entries {
+		if failure.FullMessage != "" && kb.Entries[i].regex.MatchString(failure.FullMessage) {
+			return &Match{Entry: &kb.Entries[i]}
+		}
+	}
+
+	return nil
+}
+
+// Formats the entry in the same layout as an AI analysis
+func (e *Entry) Analysis() string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("### Root Cause\n%s\n\n", strings.TrimSpace(e.Explanation)))
+
+	if len(e.Fixes) > 0 {
+		sb.WriteString("### Suggested Fixes\n")
+		for _, fix := range e.Fixes {
+			sb.WriteString(fmt.Sprintf("- %s\n", fix))
+		}
+		sb.WriteString("\n")
+	}
+
+	title := e.Title
+	if title == "" {
+		title = e.ID
+	}
+	sb.WriteString(fmt.Sprintf("_Known issue %s: %s_", e.ID, title))
+
+	return sb.String()
+}
+
+// Writes an entry to <dir>/<id>.yaml, refusing to overwrite existing entries
+func Save(dir string, entry Entry) (string, error) {
+	if entry.ID == "" {
+		return "", fmt.Errorf("known issue has no ID")
+	}
+	// The ID names a file in dir and must not lead out of it
+	if strings.ContainsAny(entry.ID, `/\`) || strings.Contains(entry.ID, "..") {
+		return "", fmt.Errorf("invalid known issue ID '%s': must not contain path separators or '..'", entry.ID)
+	}
+	if _, err := regexp.Compile(entry.Pattern); err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, entry.ID+".yaml")
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("known issue %s already exists", path)
+	}
+
+	data, err := yaml.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+var (
+	numberRegex = regexp.MustCompile(`\d+`)
+	slugRegex   = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// Builds a pattern matching an error message, allowing numbers to vary
+func PatternFromError(message string) string {
+	message = strings.TrimSpace(strings.Split(message, "\n")[0])
+
+	parts := numberRegex.Split(message, -1)
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	return strings.Join(parts, `\d+`)
+}
+
+// Derives a file-friendly entry ID from an error message
+func Slug(message string) string {
+	slug := strings.Trim(slugRegex.ReplaceAllString(strings.ToLower(message), "-"), "-")
+	if len(slug) > 48 {
+		slug = slug[:48]
+		if i := strings.LastIndex(slug, "-"); i > 0 {
+			slug = slug[:i]
+		}
+	}
+
+	// Messages without letters or digits are named by their hash
+	if slug == "" {
+		sum := sha1.Sum([]byte(message))
+		slug = "issue-" + hex.EncodeToString(sum[:4])
+	}
+	return slug
+}
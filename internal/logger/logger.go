@@ -92,7 +92,7 @@ func (l *Logger) Debugf(format string, v ...interface{}) {
 	msg := fmt.Sprintf(format, v...)
 	timestamp := time.Now().Format("15:04:05.000")
 	if l.colors {
-		l.debugColor.Printf("%s %s [DEBUG] %s\n", IconDebug, timestamp, msg)
+		l.debugColor.Fprintf(l.output, "%s %s [DEBUG] %s\n", IconDebug, timestamp, msg)
 	} else {
 		fmt.Fprintf(l.output, "%s [DEBUG] %s\n", timestamp, msg)
 	}
@@ -107,7 +107,7 @@ func (l *Logger) Verbosef(format string, v ...interface{}) {
 	defer l.mutex.Unlock()
 	msg := fmt.Sprintf(format, v...)
 	if l.colors {
-		l.verboseColor.Printf("%s [INFO] %s\n", IconVerbose, msg)
+		l.verboseColor.Fprintf(l.output, "%s [INFO] %s\n", IconVerbose, msg)
 	} else {
 		fmt.Fprintf(l.output, "%s [INFO] %s\n", IconVerbose, msg)
 	}
@@ -137,7 +137,7 @@ func (l *Logger) Successf(format string, v ...interface{}) {
 	defer l.mutex.Unlock()
 	msg := fmt.Sprintf(format, v...)
 	if l.colors {
-		l.successColor.Printf("%s [SUCCESS] %s\n", IconSuccess, msg)
+		l.successColor.Fprintf(l.output, "%s [SUCCESS] %s\n", IconSuccess, msg)
 	} else {
 		fmt.Fprintf(l.output, "%s [SUCCESS] %s\n", IconSuccess, msg)
 	}
@@ -149,7 +149,7 @@ func (l *Logger) Warnf(format string, v ...interface{}) {
 	defer l.mutex.Unlock()
 	msg := fmt.Sprintf(format, v...)
 	if l.colors {
-		l.warnColor.Printf("%s [WARN] %s\n", IconWarning, msg)
+		l.warnColor.Fprintf(l.output, "%s [WARN] %s\n", IconWarning, msg)
 	} else {
 		fmt.Fprintf(l.output, "%s [WARN] %s\n", IconWarning, msg)
 	}
@@ -168,9 +168,9 @@ func (l *Logger) PrintFileLocation(file string, line int) {
 	defer l.mutex.Unlock()
 
 	if l.colors {
-		l.fileColor.Print(file)
+		l.fileColor.Fprint(l.output, file)
 		if line > 0 {
-			l.lineColor.Printf(":%d", line)
+			l.lineColor.Fprintf(l.output, ":%d", line)
 		}
 	} else {
 		if line > 0 {
@@ -188,14 +188,14 @@ func (l *Logger) PrintTestFailure(testName, errMsg, file string, line int) {
 	defer l.mutex.Unlock()
 
 	if l.colors {
-		l.errorColor.Print("FAIL ")
+		l.errorColor.Fprint(l.output, "FAIL ")
 		fmt.Fprint(l.output, testName)
-		l.errorColor.Print(": ")
+		l.errorColor.Fprint(l.output, ": ")
 		fmt.Fprint(l.output, errMsg)
 		fmt.Fprint(l.output, " (")
-		l.fileColor.Print(file)
+		l.fileColor.Fprint(l.output, file)
 		if line > 0 {
-			l.lineColor.Printf(":%d", line)
+			l.lineColor.Fprintf(l.output, ":%d", line)
 		}
 		fmt.Fprintln(l.output, ")")
 	} else {
@@ -0,0 +1,50 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Headline shared by the chat messages
+func (s Summary) Title() string {
+	title := fmt.Sprintf("Sherlock: %d test failure(s) in %s", s.Failures, s.TestOutput)
+	if s.Branch != "" {
+		title += " on " + s.Branch
+	}
+	if s.New > 0 {
+		title += fmt.Sprintf(" (%d new)", s.New)
+	}
+	return title
+}
+
+// One line per root cause, formatted with the channel's markup
+func (s Summary) causeLines(bold func(string) string, code func(string) string) []string {
+	var lines []string
+	for _, cause := range s.Causes {
+		line := bold(cause.Test)
+		if cause.Affected > 1 {
+			line += fmt.Sprintf(" (+%d more)", cause.Affected-1)
+		}
+		if cause.Location != "" {
+			line += " at " + code(cause.Location)
+		}
+		lines = append(lines, line+": "+cause.Cause)
+	}
+
+	if s.MoreCauses > 0 {
+		lines = append(lines, fmt.Sprintf("%d more root cause(s) in the full report", s.MoreCauses))
+	}
+	return lines
+}
+
+func location(path string, line int) string {
+	if path == "" || line <= 0 {
+		return path
+	}
+	return fmt.Sprintf("%s:%d", path, line)
+}
+
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,161 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/anthonydip/sherlock/internal/report"
+)
+
+// Supported channel types
+const (
+	Slack   = "slack"
+	Teams   = "teams"
+	Webhook = "webhook"
+)
+
+var Types = []string{Slack, Teams, Webhook}
+
+// Notifications must not hold up the CI job for long
+const timeout = 10 * time.Second
+
+// Destination for failure notifications, configured in the config file
+type Channel struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+
+	// Incoming webhook URL. Environment variables like ${SLACK_URL} are
+	// expanded so secrets can stay out of the file.
+	URL string `yaml:"url"`
+
+	// Extra request headers of generic webhooks, expanded like the URL
+	Headers map[string]string `yaml:"headers"`
+
+	// Only notify about failures that are new relative to the baseline.
+	// Without a baseline every failure is new.
+	OnlyNew bool `yaml:"only_new"`
+
+	// Only notify on branches matching one of these patterns, e.g. main or
+	// release/*. Empty matches every branch.
+	Branches []string `yaml:"branches"`
+}
+
+// Run the notifications are about
+type Event struct {
+	Report *report.Report
+	Branch string
+	Commit string
+}
+
+// Label of the channel in logs and errors
+func (c Channel) String() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Type
+}
+
+// Checks the channel can be sent to
+func (c Channel) Validate() error {
+	switch c.Type {
+	case Slack, Teams, Webhook:
+	default:
+		return fmt.Errorf("unknown type '%s' (supported: %s)", c.Type, strings.Join(Types, ", "))
+	}
+
+	if c.URL == "" {
+		return fmt.Errorf("missing url")
+	}
+	for _, pattern := range c.Branches {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid branch pattern '%s': %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Reports whether the channel wants notifications for the branch
+func (c Channel) MatchesBranch(branch string) bool {
+	if len(c.Branches) == 0 {
+		return true
+	}
+
+	for _, pattern := range c.Branches {
+		if matched, _ := path.Match(pattern, branch); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Sends the event to the channel. Returns false when the channel's filters
+// leave nothing to notify about.
+func Send(channel Channel, event Event) (bool, error) {
+	if err := channel.Validate(); err != nil {
+		return false, err
+	}
+	if !channel.MatchesBranch(event.Branch) {
+		return false, nil
+	}
+
+	summary := Summarize(event, channel.OnlyNew)
+	if summary.Failures == 0 {
+		return false, nil
+	}
+
+	var payload any
+	switch channel.Type {
+	case Slack:
+		payload = slackPayload(summary)
+	case Teams:
+		payload = teamsPayload(summary)
+	default:
+		payload = webhookPayload(summary)
+	}
+
+	headers := make(map[string]string)
+	for key, value := range channel.Headers {
+		headers[key] = os.ExpandEnv(value)
+	}
+
+	if err := post(os.ExpandEnv(channel.URL), headers, payload); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func post(url string, headers map[string]string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package notify
+
+import (
+	"strings"
+)
+
+// Slack incoming webhook message. The plain text field is also understood by
+// Slack-compatible services like Mattermost and Rocket.Chat.
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// Slack escapes only these characters in message text
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func slackPayload(summary Summary) slackMessage {
+	bold := func(text string) string { return "*" + slackEscaper.Replace(text) + "*" }
+	code := func(text string) string { return "`" + slackEscaper.Replace(text) + "`" }
+
+	lines := []string{bold(summary.Title())}
+	for _, line := range summary.causeLines(bold, code) {
+		lines = append(lines, "• "+line)
+	}
+
+	return slackMessage{Text: strings.Join(lines, "\n")}
+}
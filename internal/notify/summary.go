@@ -0,0 +1,112 @@
+package notify
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/anthonydip/sherlock/internal/ai"
+	"github.com/anthonydip/sherlock/internal/ci"
+	"github.com/anthonydip/sherlock/internal/report"
+)
+
+// Number of root causes listed in a notification
+const maxCauses = 3
+
+// Compact overview of a run's failures shared by all channel types
+type Summary struct {
+	TestOutput string
+	Branch     string
+	Commit     string
+
+	Failures int
+	New      int // Failures new relative to the baseline, when compared
+
+	// Root causes affecting the most failures first
+	Causes []Cause
+	// Root causes left out of Causes
+	MoreCauses int
+
+	// Every failure, for generic webhooks
+	Tests []Test
+}
+
+type Cause struct {
+	Test     string `json:"test"`
+	Location string `json:"location,omitempty"`
+	Cause    string `json:"cause"`
+	Affected int    `json:"affected"`
+}
+
+type Test struct {
+	Name     string `json:"name"`
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Error    string `json:"error"`
+	Status   string `json:"status,omitempty"`
+	Flaky    bool   `json:"flaky,omitempty"`
+	Cluster  int    `json:"cluster,omitempty"`
+	Analysis string `json:"root_cause,omitempty"`
+}
+
+// Summarizes the event's failures, only those new relative to the baseline
+// with onlyNew
+func Summarize(event Event, onlyNew bool) Summary {
+	rep := event.Report
+	summary := Summary{
+		TestOutput: filepath.Base(rep.TestOutput),
+		Branch:     event.Branch,
+		Commit:     event.Commit,
+	}
+
+	// Failures of a cluster share one root cause
+	causes := make(map[int]int)
+	for _, failure := range rep.Failures {
+		isNew := failure.Status == "" || failure.Status == report.StatusNew || failure.Status == report.StatusChanged
+		if onlyNew && !isNew {
+			continue
+		}
+
+		summary.Failures++
+		if failure.Status != "" && isNew {
+			summary.New++
+		}
+
+		cause := ci.Condense(ai.ParseResponse(rep.AnalysisFor(failure)).RootCause)
+		if cause == "" {
+			cause = firstLine(failure.Error)
+		}
+
+		summary.Tests = append(summary.Tests, Test{
+			Name:     failure.TestName,
+			File:     rep.RelativePath(failure),
+			Line:     failure.LineNumber,
+			Error:    firstLine(failure.Error),
+			Status:   failure.Status,
+			Flaky:    failure.Flaky != "",
+			Cluster:  failure.Cluster,
+			Analysis: cause,
+		})
+
+		if index, ok := causes[failure.Cluster]; ok && failure.Cluster > 0 {
+			summary.Causes[index].Affected++
+			continue
+		}
+		causes[failure.Cluster] = len(summary.Causes)
+		summary.Causes = append(summary.Causes, Cause{
+			Test:     failure.TestName,
+			Location: location(rep.RelativePath(failure), failure.LineNumber),
+			Cause:    cause,
+			Affected: 1,
+		})
+	}
+
+	sort.SliceStable(summary.Causes, func(i, j int) bool {
+		return summary.Causes[i].Affected > summary.Causes[j].Affected
+	})
+	if len(summary.Causes) > maxCauses {
+		summary.MoreCauses = len(summary.Causes) - maxCauses
+		summary.Causes = summary.Causes[:maxCauses]
+	}
+
+	return summary
+}
@@ -0,0 +1,51 @@
+package notify
+
+// Microsoft Teams message carrying an Adaptive Card, accepted by Workflows
+// and Office 365 connector webhooks
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string    `json:"contentType"`
+	Content     teamsCard `json:"content"`
+}
+
+type teamsCard struct {
+	Schema  string             `json:"$schema"`
+	Type    string             `json:"type"`
+	Version string             `json:"version"`
+	Body    []teamsCardElement `json:"body"`
+}
+
+type teamsCardElement struct {
+	Type   string `json:"type"`
+	Text   string `json:"text"`
+	Weight string `json:"weight,omitempty"`
+	Size   string `json:"size,omitempty"`
+	Wrap   bool   `json:"wrap"`
+}
+
+func teamsPayload(summary Summary) teamsMessage {
+	bold := func(text string) string { return "**" + text + "**" }
+	code := func(text string) string { return "`" + text + "`" }
+
+	body := []teamsCardElement{{Type: "TextBlock", Text: summary.Title(), Weight: "Bolder", Size: "Medium", Wrap: true}}
+	for _, line := range summary.causeLines(bold, code) {
+		body = append(body, teamsCardElement{Type: "TextBlock", Text: "- " + line, Wrap: true})
+	}
+
+	return teamsMessage{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content: teamsCard{
+				Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+				Type:    "AdaptiveCard",
+				Version: "1.4",
+				Body:    body,
+			},
+		}},
+	}
+}
@@ -0,0 +1,28 @@
+package notify
+
+// Generic webhook payload with every failure for custom integrations
+type webhookMessage struct {
+	Event      string  `json:"event"`
+	Title      string  `json:"title"`
+	TestOutput string  `json:"test_output"`
+	Branch     string  `json:"branch,omitempty"`
+	Commit     string  `json:"commit,omitempty"`
+	Failures   int     `json:"failures"`
+	New        int     `json:"new"`
+	Causes     []Cause `json:"root_causes"`
+	Tests      []Test  `json:"tests"`
+}
+
+func webhookPayload(summary Summary) webhookMessage {
+	return webhookMessage{
+		Event:      "test_failures",
+		Title:      summary.Title(),
+		TestOutput: summary.TestOutput,
+		Branch:     summary.Branch,
+		Commit:     summary.Commit,
+		Failures:   summary.Failures,
+		New:        summary.New,
+		Causes:     summary.Causes,
+		Tests:      summary.Tests,
+	}
+}
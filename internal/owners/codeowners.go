@@ -0,0 +1,155 @@
+package owners
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/anthonydip/sherlock/internal/logger"
+)
+
+// Locations searched for a CODEOWNERS file, in the order GitHub uses
+var codeOwnersPaths = []string{
+	filepath.Join(".github", "CODEOWNERS"),
+	"CODEOWNERS",
+	filepath.Join("docs", "CODEOWNERS"),
+}
+
+type CodeOwners struct {
+	Path  string // Repo-relative path of the CODEOWNERS file
+	Rules []Rule
+}
+
+type Rule struct {
+	Pattern string
+	Owners  []string
+	Line    int
+
+	regex *regexp.Regexp
+}
+
+// Loads the CODEOWNERS file of a repository, returning nil if there is none
+func Load(repoPath string) (*CodeOwners, error) {
+	for _, path := range codeOwnersPaths {
+		file, err := os.Open(filepath.Join(repoPath, path))
+		if err != nil {
+			continue
+		}
+		defer file.Close()
+
+		logger.GlobalLogger.Verbosef("Loading code owners from %s", path)
+		return Parse(filepath.ToSlash(path), file)
+	}
+
+	logger.GlobalLogger.Debugf("No CODEOWNERS file found in %s", repoPath)
+	return nil, nil
+}
+
+func Parse(path string, r io.Reader) (*CodeOwners, error) {
+	codeOwners := &CodeOwners{Path: path}
+
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		// Skip comments and GitLab section headers
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") || strings.HasPrefix(line, "^[") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		var owners []string
+		for _, field := range fields[1:] {
+			if strings.HasPrefix(field, "#") {
+				break
+			}
+			owners = append(owners, field)
+		}
+
+		regex, err := compilePattern(fields[0])
+		if err != nil {
+			logger.GlobalLogger.Warnf("Ignoring invalid pattern %q in %s:%d", fields[0], path, lineNumber)
+			continue
+		}
+
+		codeOwners.Rules = append(codeOwners.Rules, Rule{
+			Pattern: fields[0],
+			Owners:  owners,
+			Line:    lineNumber,
+			regex:   regex,
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	logger.GlobalLogger.Debugf("Parsed %d code owner rule(s) from %s", len(codeOwners.Rules), path)
+	return codeOwners, nil
+}
+
+// Returns the rule owning a repo-relative path, the last matching rule taking precedence
+func (c *CodeOwners) Match(path string) *Rule {
+	if c == nil {
+		return nil
+	}
+
+	path = strings.TrimPrefix(filepath.ToSlash(path), "/")
+	for i := len(c.Rules) - 1; i >= 0; i-- {
+		if c.Rules[i].regex.MatchString(path) {
+			return &c.Rules[i]
+		}
+	}
+
+	return nil
+}
+
+// Converts a gitignore-style pattern into a regex matching the path itself
+// and, for directories, everything beneath it
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	// Directory patterns end in a slash or name a path without wildcards,
+	// while "docs/*" only matches the files directly in docs
+	directory := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+	if !directory {
+		directory = !strings.ContainsAny(pattern[strings.LastIndex(pattern, "/")+1:], "*?")
+	}
+
+	// Patterns with a slash other than a trailing one are relative to the root
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	var expr strings.Builder
+	if anchored {
+		expr.WriteString("^")
+	} else {
+		expr.WriteString("^(?:.*/)?")
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			expr.WriteString("[^/]*")
+		case pattern[i] == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		}
+	}
+
+	if directory {
+		expr.WriteString("(?:/.*)?")
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
@@ -0,0 +1,85 @@
+package owners
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A person or team to involve in a failure, with why they were suggested
+type Contact struct {
+	Name    string   `json:"name"`
+	Reasons []string `json:"reasons"`
+}
+
+// Collects contacts from several sources, merging duplicates
+type Contacts struct {
+	list  []Contact
+	index map[string]int
+}
+
+func NewContacts() *Contacts {
+	return &Contacts{index: make(map[string]int)}
+}
+
+func (c *Contacts) Add(name string, reason string) {
+	key := contactKey(name)
+	if key == "" {
+		return
+	}
+
+	i, ok := c.index[key]
+	if !ok {
+		c.index[key] = len(c.list)
+		c.list = append(c.list, Contact{Name: name, Reasons: []string{reason}})
+		return
+	}
+
+	for _, existing := range c.list[i].Reasons {
+		if existing == reason {
+			return
+		}
+	}
+	c.list[i].Reasons = append(c.list[i].Reasons, reason)
+
+	// Prefer the more descriptive "Name <email>" form
+	if strings.Contains(name, "<") && !strings.Contains(c.list[i].Name, "<") {
+		c.list[i].Name = name
+	}
+}
+
+// Returns the contacts, the ones with the most reasons first
+func (c *Contacts) List() []Contact {
+	contacts := append([]Contact(nil), c.list...)
+	sort.SliceStable(contacts, func(i, j int) bool {
+		return len(contacts[i].Reasons) > len(contacts[j].Reasons)
+	})
+	return contacts
+}
+
+// Identifies contacts by email when known, so blame authors and
+// CODEOWNERS email entries merge
+func contactKey(name string) string {
+	if start := strings.Index(name, "<"); start >= 0 {
+		if end := strings.Index(name[start:], ">"); end > 0 {
+			name = name[start+1 : start+end]
+		}
+	}
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Formats contacts as a markdown section appended to an analysis
+func FormatSection(contacts []Contact) string {
+	if len(contacts) == 0 {
+		return ""
+	}
+	return "## Owners / Suggested Contacts\n" + FormatList(contacts)
+}
+
+func FormatList(contacts []Contact) string {
+	var builder strings.Builder
+	for _, contact := range contacts {
+		fmt.Fprintf(&builder, "- %s: %s\n", contact.Name, strings.Join(contact.Reasons, "; "))
+	}
+	return builder.String()
+}
@@ -16,6 +16,7 @@ import (
 
 type JestParser struct {
 	filePath string
+	results  []TestResult
 }
 
 func NewJestParser(filePath string) *JestParser {
@@ -58,6 +59,7 @@ func (j *JestParser) Parse() ([]TestFailure, error) {
 	logger.GlobalLogger.Verbosef("Found %d test suite(s)", len(output.TestResults))
 
 	var failures []TestFailure
+	j.results = nil
 	for _, suite := range output.TestResults {
 		// NOTE: suite.Name gives the name of the file with the test cases
 		logger.GlobalLogger.Debugf("Processing suite: %s", suite.Name)
@@ -65,18 +67,29 @@ func (j *JestParser) Parse() ([]TestFailure, error) {
 		// First check suite-level message which might contain aggregated errors
 		if len(suite.AssertionResults) == 0 && suite.Message != "" {
 			if cleanMsg, location := extractErrorDetails(suite.Message); cleanMsg != "" {
+				j.results = append(j.results, TestResult{File: suite.Name, TestName: "Test Suite"})
 				failures = append(failures, TestFailure{
 					File:        suite.Name,
 					TestName:    "Test Suite",
 					Error:       cleanMsg,
 					Location:    location,
 					FullMessage: suite.Message,
+					StackFrames: findStackFrames(stripANSI(suite.Message)),
 				})
 			}
 		}
 
 		// Then process individual test results
 		for _, test := range suite.AssertionResults {
+			// Skipped and todo tests say nothing about flakiness
+			if test.Status == "passed" || test.Status == "failed" {
+				j.results = append(j.results, TestResult{
+					File:     suite.Name,
+					TestName: buildTestName(test.AncestorTitles, test.Title),
+					Passed:   test.Status == "passed",
+				})
+			}
+
 			if test.Status == "failed" {
 				logger.GlobalLogger.Verbosef("Processing failed test: %s", test.Title)
 
@@ -120,6 +133,7 @@ func extractFailure(suite TestSuite, test AssertionResult, message string) TestF
 		File:        suite.Name,
 		TestName:    buildTestName(test.AncestorTitles, test.Title),
 		FullMessage: cleanMsg,
+		StackFrames: findStackFrames(cleanMsg),
 		Context:     &TestFailureContext{},
 	}
 
@@ -193,22 +207,51 @@ func normalizePath(path string) string {
 }
 
 func findLocation(message string) string {
+	frames := findStackFrames(message)
+	if len(frames) == 0 {
+		return ""
+	}
+	return frames[0].Location()
+}
+
+// Extracts every project frame from a stack trace, most recent call first
+func findStackFrames(message string) []StackFrame {
 	// Format 1: "at function (file:line:column)"
-	re1 := regexp.MustCompile(`at .*?\((.*?):(\d+):(\d+)\)`)
+	re1 := regexp.MustCompile(`at (?:(.*?) )?\((.*?):(\d+):(\d+)\)`)
 	// Format 2: "at file:line:column"
 	re2 := regexp.MustCompile(`at (.*?):(\d+):(\d+)`)
 
+	var frames []StackFrame
+	seen := make(map[string]bool)
+
 	for _, line := range strings.Split(message, "\n") {
+		var frame StackFrame
+
 		// Try first format
-		if matches := re1.FindStringSubmatch(line); len(matches) > 3 && isProjectFile(matches[1]) {
-			return normalizePath(fmt.Sprintf("%s:%s", matches[1], matches[2]))
+		if matches := re1.FindStringSubmatch(line); len(matches) > 4 && isProjectFile(matches[2]) {
+			frame.Function = matches[1]
+			frame.File = normalizePath(matches[2])
+			frame.LineNumber, _ = strconv.Atoi(matches[3])
+			frame.Column, _ = strconv.Atoi(matches[4])
+		} else if matches := re2.FindStringSubmatch(line); len(matches) > 3 && isProjectFile(matches[1]) {
+			// Try second format
+			frame.File = normalizePath(matches[1])
+			frame.LineNumber, _ = strconv.Atoi(matches[2])
+			frame.Column, _ = strconv.Atoi(matches[3])
+		} else {
+			continue
 		}
-		// Try second format
-		if matches := re2.FindStringSubmatch(line); len(matches) > 3 && isProjectFile(matches[1]) {
-			return normalizePath(fmt.Sprintf("%s:%s", matches[1], matches[2]))
+
+		// Skip repeated frames (e.g. recursion)
+		if seen[frame.Location()] {
+			continue
 		}
+		seen[frame.Location()] = true
+
+		frames = append(frames, frame)
 	}
-	return ""
+
+	return frames
 }
 
 func isProjectFile(path string) bool {
@@ -240,6 +283,10 @@ func buildTestName(ancestors []string, title string) string {
 	return title
 }
 
+func (j *JestParser) Results() []TestResult {
+	return j.results
+}
+
 func (j *JestParser) RelevantFiles() []string {
 	return []string{"*.js", "*.ts", "*.jsx", "*.tsx", "**/__tests__/*"}
 }
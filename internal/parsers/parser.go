@@ -2,9 +2,12 @@ package parsers
 
 import (
 	"fmt"
+	"path/filepath"
+	"strings"
 
 	"github.com/anthonydip/sherlock/internal/git"
 	"github.com/anthonydip/sherlock/internal/logger"
+	"github.com/anthonydip/sherlock/internal/owners"
 )
 
 type TestFailure struct {
@@ -18,16 +21,77 @@ type TestFailure struct {
 	CodeChanges    string
 	RelatedCommits []git.CommitInfo
 
+	// Project frames from the stack trace, most recent call first
+	StackFrames []StackFrame
+
+	// Changes since the base ref to files on the stack trace
+	SuspectChanges []SuspectChange
+
+	// Code owners and recent authors of the failing code
+	Contacts []owners.Contact
+
+	// Past intermittent failures of the test, empty unless it is flaky
+	Flaky string
+
 	Context *TestFailureContext
 }
 
+type StackFrame struct {
+	Function   string
+	File       string
+	LineNumber int
+	Column     int
+
+	CodeChanges     string
+	SurroundingCode string
+	RelatedCommits  []git.CommitInfo
+}
+
+type SuspectChange struct {
+	Diff  git.FileDiff
+	Lines []int // Stack trace lines within the changed file
+
+	// Change only exists in the working tree
+	Uncommitted bool
+}
+
+func (f StackFrame) Location() string {
+	return fmt.Sprintf("%s:%d", f.File, f.LineNumber)
+}
+
+// Reports whether the frame points into a test file rather than the code under test
+func (f StackFrame) IsTestFile() bool {
+	path := filepath.ToSlash(f.File)
+	base := filepath.Base(path)
+
+	if strings.Contains(base, ".test.") || strings.Contains(base, ".spec.") {
+		return true
+	}
+
+	for _, dir := range []string{"__tests__", "__mocks__", "test", "tests"} {
+		if strings.Contains(path, "/"+dir+"/") || strings.HasPrefix(path, dir+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
 type TestFailureContext struct {
 	SurroundingCode string
 	FullFileContent string
 }
 
+// Outcome of a single test, passed or failed
+type TestResult struct {
+	File     string
+	TestName string
+	Passed   bool
+}
+
 type Parser interface {
 	Parse() ([]TestFailure, error)
+	Results() []TestResult   // Returns the outcome of every test seen by Parse
 	RelevantFiles() []string // Returns file patterns to check in git
 }
 
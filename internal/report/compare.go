@@ -0,0 +1,66 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Status of a failure relative to a baseline run
+const (
+	StatusNew          = "new"
+	StatusChanged      = "changed-error"
+	StatusStillFailing = "still-failing"
+	StatusFixed        = "fixed"
+)
+
+// Failures of the current run classified against a baseline run
+type Comparison struct {
+	Baseline     string         `json:"baseline"`
+	New          []ComparedTest `json:"new"`
+	Changed      []ComparedTest `json:"changed_error"`
+	StillFailing []ComparedTest `json:"still_failing"`
+	Fixed        []ComparedTest `json:"fixed"`
+}
+
+type ComparedTest struct {
+	TestName      string `json:"test_name"`
+	File          string `json:"file"`
+	Error         string `json:"error,omitempty"`
+	BaselineError string `json:"baseline_error,omitempty"`
+}
+
+// Lists the tests in each status, new failures first
+func (c *Comparison) Markdown() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "## Comparison with Baseline\nBaseline: `%s`\n", c.Baseline)
+
+	sections := []struct {
+		title string
+		tests []ComparedTest
+	}{
+		{"New failures", c.New},
+		{"Changed errors", c.Changed},
+		{"Still failing", c.StillFailing},
+		{"Fixed", c.Fixed},
+	}
+
+	for _, section := range sections {
+		fmt.Fprintf(&builder, "\n**%s (%d)**\n", section.title, len(section.tests))
+		for _, test := range section.tests {
+			switch {
+			case test.BaselineError != "" && test.Error != "":
+				fmt.Fprintf(&builder, "- %s: `%s` → `%s`\n", test.TestName, firstLine(test.BaselineError), firstLine(test.Error))
+			case test.Error != "":
+				fmt.Fprintf(&builder, "- %s: `%s`\n", test.TestName, firstLine(test.Error))
+			default:
+				fmt.Fprintf(&builder, "- %s\n", test.TestName)
+			}
+		}
+	}
+
+	return strings.TrimSuffix(builder.String(), "\n")
+}
+
+func firstLine(text string) string {
+	return strings.TrimSpace(strings.Split(strings.TrimSpace(text), "\n")[0])
+}
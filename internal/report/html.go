@@ -0,0 +1,190 @@
+package report
+
+import (
+	"html/template"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Failure prepared for the HTML template
+type htmlFailure struct {
+	Failure
+	Number    int
+	Code      []codeLine
+	Diff      []diffLine
+	Patch     []diffLine
+	PatchNote string
+	SharedBy  int // Number of the failure whose analysis this one shares, if any
+	ErrorLine string
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"markdown": renderMarkdown,
+	"short": func(hash string) string {
+		return hash[:min(7, len(hash))]
+	},
+	"base": filepath.Base,
+}).Parse(htmlSource))
+
+// Writes the report as a single self-contained HTML page
+func (r *Report) WriteHTML(w io.Writer) error {
+	data := struct {
+		*Report
+		Items []htmlFailure
+	}{Report: r}
+
+	// Failures of a cluster share the first member's analysis
+	firstInCluster := make(map[int]int)
+	for i, failure := range r.Failures {
+		item := htmlFailure{
+			Failure:   failure,
+			Number:    i + 1,
+			ErrorLine: firstLine(failure.Error),
+		}
+
+		if failure.Code != "" {
+			item.Code = codeLines(failure.Code, failure.Location)
+		}
+		if failure.LineChanges != "" {
+			item.Diff = diffLines(failure.LineChanges)
+		}
+		if failure.Patch != "" {
+			item.Patch = diffLines(failure.Patch)
+			item.PatchNote = VerificationNote(failure)
+		}
+
+		if failure.Cluster > 0 {
+			if first, ok := firstInCluster[failure.Cluster]; ok {
+				item.SharedBy = first
+			} else {
+				firstInCluster[failure.Cluster] = i + 1
+			}
+		}
+
+		data.Items = append(data.Items, item)
+	}
+
+	return htmlTemplate.Execute(w, data)
+}
+
+// Kept inline so the report is a single file with no external resources
+var htmlSource = strings.TrimSpace(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sherlock Report - {{base .TestOutput}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 0; color: #1f2328; background: #f6f8fa; }
+main { max-width: 1100px; margin: 0 auto; padding: 24px; }
+h1 { margin-bottom: 4px; }
+.meta { color: #656d76; margin-top: 0; }
+table.summary { width: 100%; border-collapse: collapse; background: #fff; margin: 16px 0 24px; }
+table.summary th, table.summary td { text-align: left; padding: 8px 10px; border-bottom: 1px solid #d0d7de; vertical-align: top; }
+table.summary th { background: #eaeef2; }
+.badge { display: inline-block; padding: 1px 8px; border-radius: 10px; font-size: 12px; margin-right: 4px; background: #ddf4ff; color: #0969da; white-space: nowrap; }
+.badge.flaky { background: #fff8c5; color: #9a6700; }
+.badge.known { background: #dafbe1; color: #1a7f37; }
+.badge.new, .badge.changed-error { background: #ffebe9; color: #cf222e; }
+details.failure { background: #fff; border: 1px solid #d0d7de; border-radius: 6px; margin-bottom: 12px; }
+details.failure > summary { cursor: pointer; padding: 12px 16px; font-weight: 600; }
+details.failure > div { padding: 0 16px 16px; }
+h3 { font-size: 15px; margin: 18px 0 8px; }
+pre, code { font-family: ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; font-size: 13px; }
+pre { background: #f6f8fa; border: 1px solid #d0d7de; border-radius: 6px; padding: 10px; overflow-x: auto; }
+pre.error { color: #cf222e; white-space: pre-wrap; }
+p code, li code { background: #eff1f3; padding: 1px 4px; border-radius: 4px; }
+table.code { border-collapse: collapse; width: 100%; background: #f6f8fa; border: 1px solid #d0d7de; border-radius: 6px; }
+table.code td { padding: 0 10px; white-space: pre; font-family: ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; font-size: 13px; }
+table.code td.num { color: #8c959f; text-align: right; width: 1%; user-select: none; }
+table.code tr.failing { background: #ffebe9; }
+table.code tr.failing td.num::before { content: ">> "; color: #cf222e; font-weight: 600; }
+.tok-keyword { color: #cf222e; }
+.tok-string { color: #0a3069; }
+.tok-number { color: #0550ae; }
+.tok-comment { color: #6e7781; font-style: italic; }
+.diff .add { background: #dafbe1; display: block; }
+.diff .del { background: #ffebe9; display: block; }
+.diff .ctx { display: block; }
+ol.timeline { list-style: none; padding-left: 16px; border-left: 2px solid #d0d7de; }
+ol.timeline li { position: relative; margin-bottom: 10px; }
+ol.timeline li::before { content: ""; position: absolute; left: -22px; top: 5px; width: 10px; height: 10px; border-radius: 50%; background: #0969da; }
+ol.timeline .when { color: #656d76; font-size: 13px; }
+.analysis { border-top: 1px solid #d0d7de; margin-top: 16px; }
+.analysis pre.code { background: #f6f8fa; }
+blockquote { margin: 0; padding: 0 12px; color: #656d76; border-left: 4px solid #d0d7de; }
+</style>
+</head>
+<body>
+<main>
+<h1>Sherlock Test Failure Report</h1>
+<p class="meta">{{.TestOutput}} &middot; {{len .Failures}} failure(s) &middot; generated {{.GeneratedAt.Format "2006-01-02 15:04 MST"}}</p>
+{{with .Comparison}}
+<h2>Comparison with Baseline</h2>
+<p class="meta">Baseline: <code>{{.Baseline}}</code> &middot; {{len .New}} new &middot; {{len .Changed}} changed &middot; {{len .StillFailing}} still failing &middot; {{len .Fixed}} fixed</p>
+{{if .Fixed}}<p>Fixed: {{range $i, $test := .Fixed}}{{if $i}}, {{end}}{{$test.TestName}}{{end}}</p>{{end}}
+{{end}}
+<table class="summary">
+<thead><tr><th>#</th><th>Test</th><th>Location</th><th>Error</th></tr></thead>
+<tbody>
+{{range .Items}}<tr>
+<td><a href="#failure-{{.Number}}">{{.Number}}</a></td>
+<td>{{.TestName}}<br>{{if .Status}}<span class="badge {{.Status}}">{{.Status}}</span>{{end}}{{if .KnownIssue}}<span class="badge known">known: {{.KnownIssue}}</span>{{end}}{{if .Flaky}}<span class="badge flaky">flaky</span>{{end}}{{if .Cluster}}<span class="badge">cluster {{.Cluster}}</span>{{end}}</td>
+<td><code>{{.Location}}</code></td>
+<td>{{.ErrorLine}}</td>
+</tr>
+{{end}}</tbody>
+</table>
+{{if .Analysis}}
+<h2>Combined Analysis</h2>
+<div class="analysis">{{markdown .Analysis}}</div>
+{{end}}
+<h2>Failures</h2>
+{{range .Items}}
+<details class="failure" id="failure-{{.Number}}"{{if eq .Number 1}} open{{end}}>
+<summary>{{.Number}}. {{.TestName}}</summary>
+<div>
+{{if .Flaky}}<blockquote><p><strong>Flaky test:</strong> {{.Flaky}}</p></blockquote>{{end}}
+<h3>Error</h3>
+<pre class="error">{{.Error}}</pre>
+{{if .Code}}
+<h3>Code Context <code>{{.Location}}</code></h3>
+<table class="code">
+{{range .Code}}<tr{{if .Failing}} class="failing"{{end}}><td class="num">{{.Number}}</td><td>{{.Code}}</td></tr>
+{{end}}</table>
+{{end}}
+{{if .Diff}}
+<h3>Line Changes</h3>
+<pre class="diff">{{range .Diff}}<span class="{{.Kind}}">{{.Text}}</span>{{end}}</pre>
+{{end}}
+{{if .Commits}}
+<h3>Related Commits</h3>
+<ol class="timeline">
+{{range .Commits}}<li><code>{{if .Uncommitted}}working tree{{else}}{{short .Hash}}{{end}}</code> {{.Message}}<br><span class="when">{{.Author}} &middot; {{.Date.Format "2006-01-02 15:04"}}</span></li>
+{{end}}</ol>
+{{end}}
+{{if .Contacts}}
+<h3>Owners / Suggested Contacts</h3>
+<ul>
+{{range .Contacts}}<li>{{.Name}}: {{range $i, $reason := .Reasons}}{{if $i}}; {{end}}{{$reason}}{{end}}</li>
+{{end}}</ul>
+{{end}}
+{{if .Patch}}
+<h3>Proposed Patch</h3>
+{{if .PatchNote}}<p class="analysis">{{.PatchNote}}</p>{{end}}
+<pre class="diff">{{range .Patch}}<span class="{{.Kind}}">{{.Text}}</span>{{end}}</pre>
+{{end}}
+{{if .SharedBy}}
+<p class="analysis">Same root cause as <a href="#failure-{{.SharedBy}}">failure {{.SharedBy}}</a>.</p>
+{{else if .Analysis}}
+<div class="analysis">{{markdown .Analysis}}</div>
+{{end}}
+</div>
+</details>
+{{end}}
+</main>
+</body>
+</html>
+`)
@@ -0,0 +1,143 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/anthonydip/sherlock/internal/owners"
+)
+
+// Renders the comparison with the baseline, if any, ahead of the analyses
+func (r *Report) Markdown() string {
+	analyses := r.analysesMarkdown()
+	if r.Comparison == nil {
+		return analyses
+	}
+
+	if analyses == "" {
+		return r.Comparison.Markdown()
+	}
+	return r.Comparison.Markdown() + "\n\n---\n\n" + analyses
+}
+
+// Renders the analyses followed by each failure's suggested contacts
+func (r *Report) analysesMarkdown() string {
+	// Batched failures share a single analysis
+	if r.Analysis != "" {
+		var builder strings.Builder
+		builder.WriteString(r.Analysis)
+
+		if clusters := r.formatClusters(); clusters != "" {
+			builder.WriteString("\n\n## Clusters\n" + clusters)
+		}
+
+		// Failures answered by the knowledge base are not part of the batch
+		for _, failure := range r.representatives() {
+			if failure.Analysis != "" {
+				fmt.Fprintf(&builder, "\n\n#### %s\n%s", failure.TestName, failure.Analysis)
+			}
+		}
+
+		heading := "\n\n## Proposed Patches\n"
+		for _, failure := range r.representatives() {
+			if failure.Patch == "" {
+				continue
+			}
+			fmt.Fprintf(&builder, "%s### %s\n%s", heading, failure.TestName, formatPatch(failure))
+			heading = "\n"
+		}
+
+		heading = "\n\n## Owners / Suggested Contacts\n"
+		for _, failure := range r.representatives() {
+			if len(failure.Contacts) == 0 {
+				continue
+			}
+			fmt.Fprintf(&builder, "%s### %s\n%s", heading, failure.TestName, owners.FormatList(failure.Contacts))
+			heading = "\n"
+		}
+
+		return builder.String()
+	}
+
+	var sections []string
+	for _, failure := range r.representatives() {
+		section := r.formatAffected(failure) + failure.Analysis
+		if failure.Flaky != "" {
+			section = fmt.Sprintf("> **Flaky test:** %s\n\n%s", failure.Flaky, section)
+		}
+		if failure.Patch != "" {
+			section += "\n\n### Proposed Patch\n" + formatPatch(failure)
+		}
+		if contacts := owners.FormatSection(failure.Contacts); contacts != "" {
+			section += "\n\n" + contacts
+		}
+		sections = append(sections, section)
+	}
+
+	return strings.Join(sections, "\n\n---\n\n")
+}
+
+func formatPatch(failure Failure) string {
+	patch := "```diff\n" + strings.TrimRight(failure.Patch, "\n") + "\n```\n"
+	if note := VerificationNote(failure); note != "" {
+		patch = "_" + note + "_\n\n" + patch
+	}
+	return patch
+}
+
+// Returns the first failure of each cluster, or every failure without clusters
+func (r *Report) representatives() []Failure {
+	var failures []Failure
+	seen := make(map[int]bool)
+
+	for _, failure := range r.Failures {
+		if failure.Cluster > 0 {
+			if seen[failure.Cluster] {
+				continue
+			}
+			seen[failure.Cluster] = true
+		}
+		failures = append(failures, failure)
+	}
+
+	return failures
+}
+
+// Lists the tests sharing the failure's analysis, empty when it stands alone
+func (r *Report) formatAffected(failure Failure) string {
+	if failure.Cluster < 1 || failure.Cluster > len(r.Clusters) {
+		return ""
+	}
+
+	cluster := r.Clusters[failure.Cluster-1]
+	if cluster.Count < 2 {
+		return ""
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "**Affected tests (%d):**\n", cluster.Count)
+	for _, test := range cluster.Tests {
+		fmt.Fprintf(&builder, "- %s\n", test)
+	}
+	builder.WriteString("\n")
+
+	return builder.String()
+}
+
+// Lists the clusters with more than one failure
+func (r *Report) formatClusters() string {
+	var builder strings.Builder
+
+	for i, cluster := range r.Clusters {
+		if cluster.Count < 2 {
+			continue
+		}
+
+		fmt.Fprintf(&builder, "\n### Cluster %d (%d tests)\n`%s`\n", i+1, cluster.Count, cluster.Signature)
+		for _, test := range cluster.Tests {
+			fmt.Fprintf(&builder, "- %s\n", test)
+		}
+	}
+
+	return strings.TrimSuffix(builder.String(), "\n")
+}
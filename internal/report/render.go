@@ -0,0 +1,241 @@
+package report
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	headingRegex = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	bulletRegex  = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	orderedRegex = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+	quoteRegex   = regexp.MustCompile(`^>\s?(.*)$`)
+	ruleRegex    = regexp.MustCompile(`^\s*(?:-{3,}|\*{3,})\s*$`)
+
+	boldRegex   = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	italicRegex = regexp.MustCompile(`(^|[^\w*])[*_]([^*_\s][^*_]*?)[*_]($|[^\w*])`)
+	linkRegex   = regexp.MustCompile(`\[([^\]]+)\]\((https?://[^)\s]+)\)`)
+
+	// Code context lines: ">> L12: code" for the failing line, "   L11: code" otherwise
+	contextLineRegex = regexp.MustCompile(`^(>>|  ) L(\d+): ?(.*)$`)
+)
+
+// Renders the subset of markdown used in analyses: headings, lists, fenced
+// code, block quotes, rules, paragraphs, and inline code, bold, italics and links
+func renderMarkdown(text string) template.HTML {
+	var out strings.Builder
+	var paragraph []string
+	var list, quote string
+	var code []string
+	inCode := false
+
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			fmt.Fprintf(&out, "<p>%s</p>\n", renderInline(strings.Join(paragraph, " ")))
+			paragraph = nil
+		}
+	}
+	closeList := func() {
+		if list != "" {
+			fmt.Fprintf(&out, "</%s>\n", list)
+			list = ""
+		}
+	}
+	closeQuote := func() {
+		if quote != "" {
+			fmt.Fprintf(&out, "<blockquote><p>%s</p></blockquote>\n", renderInline(strings.TrimSpace(quote)))
+			quote = ""
+		}
+	}
+	flush := func() {
+		flushParagraph()
+		closeList()
+		closeQuote()
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			if inCode {
+				fmt.Fprintf(&out, "<pre class=\"code\"><code>%s</code></pre>\n", html.EscapeString(strings.Join(code, "\n")))
+				code, inCode = nil, false
+			} else {
+				flush()
+				inCode = true
+			}
+			continue
+		}
+		if inCode {
+			code = append(code, line)
+			continue
+		}
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			flush()
+		case ruleRegex.MatchString(line):
+			flush()
+			out.WriteString("<hr>\n")
+		case headingRegex.MatchString(line):
+			flush()
+			match := headingRegex.FindStringSubmatch(line)
+			level := len(match[1])
+			fmt.Fprintf(&out, "<h%d>%s</h%d>\n", level, renderInline(match[2]), level)
+		case bulletRegex.MatchString(line), orderedRegex.MatchString(line):
+			flushParagraph()
+			closeQuote()
+
+			kind, match := "ul", bulletRegex.FindStringSubmatch(line)
+			if match == nil {
+				kind, match = "ol", orderedRegex.FindStringSubmatch(line)
+			}
+			if list != kind {
+				closeList()
+				fmt.Fprintf(&out, "<%s>\n", kind)
+				list = kind
+			}
+			fmt.Fprintf(&out, "<li>%s</li>\n", renderInline(match[1]))
+		case quoteRegex.MatchString(line):
+			flushParagraph()
+			closeList()
+			quote += " " + quoteRegex.FindStringSubmatch(line)[1]
+		default:
+			closeList()
+			closeQuote()
+			paragraph = append(paragraph, strings.TrimSpace(line))
+		}
+	}
+
+	// Unterminated code blocks keep their content
+	if inCode {
+		fmt.Fprintf(&out, "<pre class=\"code\"><code>%s</code></pre>\n", html.EscapeString(strings.Join(code, "\n")))
+	}
+	flush()
+
+	return template.HTML(out.String())
+}
+
+// Renders inline code spans, bold, italics and links, escaping everything else
+func renderInline(text string) string {
+	parts := strings.Split(text, "`")
+
+	var out strings.Builder
+	for i, part := range parts {
+		// Odd parts are inside backticks, unless the last backtick is unmatched
+		if i%2 == 1 && i < len(parts)-1 {
+			fmt.Fprintf(&out, "<code>%s</code>", html.EscapeString(part))
+			continue
+		}
+		if i%2 == 1 {
+			out.WriteString("`")
+		}
+
+		escaped := html.EscapeString(part)
+		escaped = linkRegex.ReplaceAllString(escaped, `<a href="$2">$1</a>`)
+		escaped = boldRegex.ReplaceAllString(escaped, "<strong>$1</strong>")
+		escaped = italicRegex.ReplaceAllString(escaped, "$1<em>$2</em>$3")
+		out.WriteString(escaped)
+	}
+
+	return out.String()
+}
+
+// A line of code context prepared for display
+type codeLine struct {
+	Number  string
+	Code    template.HTML
+	Failing bool
+}
+
+// Splits code context into highlighted lines, keeping the failing line marker
+func codeLines(context, path string) []codeLine {
+	var lines []codeLine
+	for _, line := range strings.Split(strings.TrimRight(context, "\n"), "\n") {
+		match := contextLineRegex.FindStringSubmatch(line)
+		if match == nil {
+			lines = append(lines, codeLine{Code: highlight(line, path)})
+			continue
+		}
+		lines = append(lines, codeLine{
+			Number:  match[2],
+			Code:    highlight(match[3], path),
+			Failing: match[1] == ">>",
+		})
+	}
+	return lines
+}
+
+var (
+	keywords = map[string]bool{
+		"async": true, "await": true, "break": true, "case": true, "catch": true, "class": true,
+		"const": true, "continue": true, "def": true, "default": true, "defer": true, "delete": true,
+		"do": true, "else": true, "export": true, "extends": true, "false": true, "finally": true,
+		"for": true, "from": true, "func": true, "function": true, "go": true, "if": true,
+		"import": true, "in": true, "instanceof": true, "interface": true, "let": true, "new": true,
+		"nil": true, "None": true, "null": true, "package": true, "raise": true, "return": true,
+		"static": true, "struct": true, "switch": true, "this": true, "throw": true, "true": true,
+		"True": true, "False": true, "try": true, "type": true, "typeof": true, "undefined": true,
+		"var": true, "void": true, "while": true, "with": true, "yield": true,
+	}
+
+	slashTokenRegex = regexp.MustCompile("(//.*$|/\\*.*?\\*/)|(\"(?:\\\\.|[^\"\\\\])*\"|'(?:\\\\.|[^'\\\\])*'|`[^`]*`)|(\\b\\d+(?:\\.\\d+)?\\b)|([A-Za-z_$][\\w$]*)")
+	hashTokenRegex  = regexp.MustCompile("(#.*$)|(\"(?:\\\\.|[^\"\\\\])*\"|'(?:\\\\.|[^'\\\\])*')|(\\b\\d+(?:\\.\\d+)?\\b)|([A-Za-z_][\\w]*)")
+)
+
+// Highlights comments, strings, numbers and keywords of a line of code,
+// choosing the comment syntax from the file extension
+func highlight(line, path string) template.HTML {
+	tokens := slashTokenRegex
+	switch filepath.Ext(path) {
+	case ".py", ".rb", ".sh", ".yaml", ".yml":
+		tokens = hashTokenRegex
+	}
+
+	var out strings.Builder
+	last := 0
+	for _, match := range tokens.FindAllStringSubmatchIndex(line, -1) {
+		out.WriteString(html.EscapeString(line[last:match[0]]))
+		token := html.EscapeString(line[match[0]:match[1]])
+
+		switch {
+		case match[2] >= 0:
+			fmt.Fprintf(&out, `<span class="tok-comment">%s</span>`, token)
+		case match[4] >= 0:
+			fmt.Fprintf(&out, `<span class="tok-string">%s</span>`, token)
+		case match[6] >= 0:
+			fmt.Fprintf(&out, `<span class="tok-number">%s</span>`, token)
+		case keywords[line[match[0]:match[1]]]:
+			fmt.Fprintf(&out, `<span class="tok-keyword">%s</span>`, token)
+		default:
+			out.WriteString(token)
+		}
+		last = match[1]
+	}
+	out.WriteString(html.EscapeString(line[last:]))
+
+	return template.HTML(out.String())
+}
+
+// A line of a diff with its change kind
+type diffLine struct {
+	Text string
+	Kind string // "add", "del" or "ctx"
+}
+
+func diffLines(diff string) []diffLine {
+	var lines []diffLine
+	for _, line := range strings.Split(strings.TrimRight(diff, "\n"), "\n") {
+		kind := "ctx"
+		switch {
+		case strings.HasPrefix(line, "+"):
+			kind = "add"
+		case strings.HasPrefix(line, "-"):
+			kind = "del"
+		}
+		lines = append(lines, diffLine{Text: line, Kind: kind})
+	}
+	return lines
+}
@@ -0,0 +1,311 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anthonydip/sherlock/internal/git"
+	"github.com/anthonydip/sherlock/internal/owners"
+	"github.com/anthonydip/sherlock/internal/parsers"
+	"github.com/anthonydip/sherlock/internal/source"
+)
+
+// Output formats supported by analyze
+const (
+	FormatMarkdown = "markdown"
+	FormatJSON     = "json"
+	FormatHTML     = "html"
+	FormatSARIF    = "sarif"
+)
+
+var Formats = []string{FormatMarkdown, FormatJSON, FormatHTML, FormatSARIF}
+
+// Machine-readable result of an analysis run
+type Report struct {
+	TestOutput  string    `json:"test_output"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Failures    []Failure `json:"failures"`
+
+	// Directory source paths are resolved against, the repository when in Git
+	SourceRoot string `json:"source_root,omitempty"`
+
+	// Combined analysis when failures were batched into one request
+	Analysis string `json:"analysis,omitempty"`
+
+	// Groups of failures that shared one analysis
+	Clusters []Cluster `json:"clusters,omitempty"`
+
+	// Classification against a baseline run, set by diff
+	Comparison *Comparison `json:"comparison,omitempty"`
+}
+
+// Failures with the same signature, analyzed once
+type Cluster struct {
+	Signature string   `json:"signature"`
+	Count     int      `json:"count"`
+	Tests     []string `json:"tests"`
+}
+
+type Failure struct {
+	TestName   string `json:"test_name"`
+	File       string `json:"file"`
+	Location   string `json:"location,omitempty"`
+	LineNumber int    `json:"line,omitempty"`
+	Error      string `json:"error"`
+	Message    string `json:"message,omitempty"`
+
+	// Code around the failing line, with the line marked by ">>"
+	Code string `json:"code,omitempty"`
+
+	// Change that last touched the failing line and the commits behind it
+	LineChanges string   `json:"line_changes,omitempty"`
+	Commits     []Commit `json:"commits,omitempty"`
+
+	Analysis string           `json:"analysis,omitempty"`
+	Contacts []owners.Contact `json:"contacts,omitempty"`
+
+	// Fix proposed by the AI as a unified diff, checked to apply cleanly
+	Patch string `json:"patch,omitempty"`
+
+	// Outcome of rerunning the failing tests with the patch, when verified
+	Verification string `json:"verification,omitempty"`
+
+	// ID of the knowledge base entry the analysis came from
+	KnownIssue string `json:"known_issue,omitempty"`
+
+	// Number of the cluster the failure belongs to, starting at 1
+	Cluster int `json:"cluster,omitempty"`
+
+	// Past intermittent failures, set when the test is flaky
+	Flaky string `json:"flaky,omitempty"`
+
+	// Status relative to the baseline run, set by diff
+	Status string `json:"status,omitempty"`
+}
+
+// Outcomes of verifying a patch
+const (
+	VerificationPassed = "passed"
+	VerificationFailed = "failed"
+)
+
+type Commit struct {
+	Hash        string    `json:"hash"`
+	Author      string    `json:"author"`
+	Date        time.Time `json:"date"`
+	Message     string    `json:"message"`
+	Uncommitted bool      `json:"uncommitted,omitempty"`
+}
+
+func New(testOutput string) *Report {
+	return &Report{
+		TestOutput:  testOutput,
+		GeneratedAt: time.Now().UTC(),
+	}
+}
+
+func (r *Report) AddFailure(failure parsers.TestFailure, analysis string) *Failure {
+	entry := Failure{
+		TestName:    failure.TestName,
+		File:        failure.File,
+		Location:    failure.Location,
+		LineNumber:  failure.LineNumber,
+		Error:       failure.Error,
+		Message:     failure.FullMessage,
+		LineChanges: failure.CodeChanges,
+		Analysis:    analysis,
+		Contacts:    failure.Contacts,
+		Flaky:       failure.Flaky,
+	}
+
+	if failure.Context != nil {
+		entry.Code = failure.Context.SurroundingCode
+	}
+
+	for _, commit := range failure.RelatedCommits {
+		entry.Commits = append(entry.Commits, Commit{
+			Hash:        commit.Hash,
+			Author:      commit.Author,
+			Date:        commit.Date,
+			Message:     commit.Message,
+			Uncommitted: commit.Uncommitted,
+		})
+	}
+
+	r.Failures = append(r.Failures, entry)
+	return &r.Failures[len(r.Failures)-1]
+}
+
+// Rebuilds the parsed failure from the report, for prompts about a past
+// analysis. Stack frames and branch changes are not kept in reports.
+func (f Failure) TestFailure() parsers.TestFailure {
+	failure := parsers.TestFailure{
+		File:        f.File,
+		TestName:    f.TestName,
+		Error:       f.Error,
+		Location:    f.Location,
+		FullMessage: f.Message,
+		LineNumber:  f.LineNumber,
+		CodeChanges: f.LineChanges,
+		Contacts:    f.Contacts,
+		Flaky:       f.Flaky,
+	}
+
+	if f.Code != "" {
+		failure.Context = &parsers.TestFailureContext{SurroundingCode: f.Code}
+	}
+
+	for _, commit := range f.Commits {
+		failure.RelatedCommits = append(failure.RelatedCommits, git.CommitInfo{
+			Hash:        commit.Hash,
+			Author:      commit.Author,
+			Date:        commit.Date,
+			Message:     commit.Message,
+			Uncommitted: commit.Uncommitted,
+		})
+	}
+
+	return failure
+}
+
+// Records a cluster and assigns the failures at the given indexes to it
+func (r *Report) AddCluster(signature string, indexes []int) {
+	cluster := Cluster{Signature: signature, Count: len(indexes)}
+	for _, i := range indexes {
+		r.Failures[i].Cluster = len(r.Clusters) + 1
+		cluster.Tests = append(cluster.Tests, r.Failures[i].TestName)
+	}
+	r.Clusters = append(r.Clusters, cluster)
+}
+
+// Returns the analysis of a failure, taken from its section of the combined
+// analysis when failures were batched. Only the first failure of a cluster
+// is part of the batch, so the others use its section.
+func (r *Report) AnalysisFor(failure Failure) string {
+	if failure.Analysis != "" || r.Analysis == "" {
+		return failure.Analysis
+	}
+
+	names := map[string]bool{failure.TestName: true}
+	if failure.Cluster > 0 && failure.Cluster <= len(r.Clusters) {
+		for _, test := range r.Clusters[failure.Cluster-1].Tests {
+			names[test] = true
+		}
+	}
+
+	for _, section := range strings.Split("\n"+r.Analysis, "\n#### ")[1:] {
+		heading, body, _ := strings.Cut(section, "\n")
+		if names[strings.TrimSpace(heading)] {
+			return strings.TrimSpace(body)
+		}
+	}
+	return ""
+}
+
+// Returns the names of the tests sharing the failure's analysis, including
+// its own
+func (r *Report) ClusterTests(failure Failure) []string {
+	if failure.Cluster < 1 || failure.Cluster > len(r.Clusters) {
+		return []string{failure.TestName}
+	}
+	return r.Clusters[failure.Cluster-1].Tests
+}
+
+// Returns the failure holding the patch proposed for a failure, as cluster
+// members share the patch of the failure analyzed for the cluster. The
+// patch is empty when none was proposed.
+func (r *Report) PatchFor(failure Failure) Failure {
+	if failure.Patch != "" || failure.Cluster == 0 {
+		return failure
+	}
+
+	for _, other := range r.Failures {
+		if other.Cluster == failure.Cluster && other.Patch != "" {
+			return other
+		}
+	}
+	return failure
+}
+
+// Describes whether the failing tests passed with the patch, empty when it
+// was not verified
+func VerificationNote(failure Failure) string {
+	switch failure.Verification {
+	case VerificationPassed:
+		return "Verified: the failing tests pass with this patch."
+	case VerificationFailed:
+		return "Not verified: the failing tests still fail with this patch."
+	default:
+		return ""
+	}
+}
+
+// Returns the path of the failing file relative to the source root, or the
+// path as reported when it cannot be found there
+func (r *Report) RelativePath(failure Failure) string {
+	location := failure.Location
+	if location == "" {
+		location = failure.File
+	}
+	if location == "" {
+		return ""
+	}
+
+	if r.SourceRoot != "" {
+		if relative, err := git.NormalizeTestPath(location, r.SourceRoot); err == nil && !strings.HasPrefix(relative, "../") {
+			return relative
+		}
+	}
+	return filepath.ToSlash(source.LocationPath(location))
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(r)
+}
+
+// Reads a report previously written with --format json
+func Load(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("invalid report %s: %w", path, err)
+	}
+
+	return &report, nil
+}
+
+// Validates an output format name
+func ValidateFormat(format string) error {
+	for _, supported := range Formats {
+		if format == supported {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown format '%s' (supported: %s)", format, strings.Join(Formats, ", "))
+}
+
+// Returns the file extension used for a format
+func Extension(format string) string {
+	switch format {
+	case FormatJSON:
+		return ".json"
+	case FormatHTML:
+		return ".html"
+	case FormatSARIF:
+		return ".sarif"
+	default:
+		return ".md"
+	}
+}
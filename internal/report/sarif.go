@@ -0,0 +1,193 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/anthonydip/sherlock/internal/ai"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	toolURI      = "https://github.com/anthonydip/sherlock"
+)
+
+// Subset of the SARIF 2.1.0 object model used by the report
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID     string          `json:"ruleId"`
+	RuleIndex  int             `json:"ruleIndex"`
+	Level      string          `json:"level"`
+	Message    sarifMessage    `json:"message"`
+	Locations  []sarifLocation `json:"locations,omitempty"`
+	Properties map[string]any  `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text     string `json:"text"`
+	Markdown string `json:"markdown,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// Writes one SARIF result per failure, with the test as its rule. SARIF fix
+// objects require concrete file edits, so suggested fixes are part of the
+// message and listed under the result's properties.
+func (r *Report) WriteSARIF(w io.Writer) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "sherlock",
+			InformationURI: toolURI,
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+
+	rules := make(map[string]int)
+	for _, failure := range r.Failures {
+		index, ok := rules[failure.TestName]
+		if !ok {
+			index = len(run.Tool.Driver.Rules)
+			rules[failure.TestName] = index
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+				ID:               failure.TestName,
+				Name:             failure.TestName,
+				ShortDescription: sarifMessage{Text: fmt.Sprintf("Test '%s' failed", failure.TestName)},
+			})
+		}
+
+		response := ai.ParseResponse(r.AnalysisFor(failure))
+
+		result := sarifResult{
+			RuleID:    failure.TestName,
+			RuleIndex: index,
+			Level:     "error",
+			Message:   sarifFailureMessage(failure, response),
+		}
+
+		// Intermittent failures are not blocking findings
+		if failure.Flaky != "" {
+			result.Level = "warning"
+		}
+
+		if location := r.sarifLocation(failure); location != nil {
+			result.Locations = []sarifLocation{*location}
+		}
+
+		properties := make(map[string]any)
+		if len(response.Fixes) > 0 {
+			properties["fixes"] = response.Fixes
+		}
+		if failure.KnownIssue != "" {
+			properties["knownIssue"] = failure.KnownIssue
+		}
+		if failure.Flaky != "" {
+			properties["flaky"] = failure.Flaky
+		}
+		if failure.Status != "" {
+			properties["status"] = failure.Status
+		}
+		if len(properties) > 0 {
+			result.Properties = properties
+		}
+
+		run.Results = append(run.Results, result)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{run},
+	})
+}
+
+func sarifFailureMessage(failure Failure, response ai.Response) sarifMessage {
+	summary := fmt.Sprintf("Test '%s' failed: %s", failure.TestName, firstLine(failure.Error))
+
+	text := []string{summary}
+	markdown := []string{fmt.Sprintf("Test **%s** failed: `%s`", failure.TestName, firstLine(failure.Error))}
+
+	if response.RootCause != "" {
+		text = append(text, "Root cause: "+response.RootCause)
+		markdown = append(markdown, "**Root cause:** "+response.RootCause)
+	}
+
+	if len(response.Fixes) > 0 {
+		fixes := "Suggested fixes:\n- " + strings.Join(response.Fixes, "\n- ")
+		text = append(text, fixes)
+		markdown = append(markdown, "**Suggested fixes:**\n- "+strings.Join(response.Fixes, "\n- "))
+	}
+
+	return sarifMessage{
+		Text:     strings.Join(text, "\n\n"),
+		Markdown: strings.Join(markdown, "\n\n"),
+	}
+}
+
+// Locates the failing line so code scanning tools can annotate it
+func (r *Report) sarifLocation(failure Failure) *sarifLocation {
+	uri := r.RelativePath(failure)
+	if uri == "" {
+		return nil
+	}
+
+	physical := sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: uri}}
+	if strings.HasPrefix(uri, "/") {
+		physical.ArtifactLocation.URI = "file://" + uri
+	} else {
+		physical.ArtifactLocation.URIBaseID = "%SRCROOT%"
+	}
+	if failure.LineNumber > 0 && failure.Location != "" {
+		physical.Region = &sarifRegion{StartLine: failure.LineNumber}
+	}
+
+	return &sarifLocation{PhysicalLocation: physical}
+}
@@ -1,4 +1,4 @@
-package git
+package source
 
 import (
 	"fmt"
@@ -6,15 +6,19 @@ import (
 	"strings"
 )
 
-func (r *Repository) GetCodeContext(path string, lineNum int, contextLines int) (string, error) {
+func GetCodeContext(path string, lineNum int, contextLines int) (string, error) {
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return "", err
 	}
 
 	lines := strings.Split(string(content), "\n")
+	if lineNum < 1 || lineNum > len(lines) {
+		return "", fmt.Errorf("line %d out of range in %s", lineNum, path)
+	}
+
 	start := max(0, lineNum-1-contextLines) // lineNum is 1-based
-	end := min(len(lines), lineNum-1+contextLines)
+	end := min(len(lines)-1, lineNum-1+contextLines)
 
 	var builder strings.Builder
 	for i := start; i <= end; i++ {
@@ -27,7 +31,7 @@ func (r *Repository) GetCodeContext(path string, lineNum int, contextLines int)
 	return builder.String(), nil
 }
 
-func (r *Repository) GetFullFileContent(path string) (string, error) {
+func GetFullFileContent(path string) (string, error) {
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return "", err
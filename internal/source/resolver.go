@@ -0,0 +1,66 @@
+package source
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anthonydip/sherlock/internal/logger"
+)
+
+// Locates source files referenced by test output on the local machine. Test
+// output from CI contains paths of the CI checkout, so paths that do not
+// exist are matched by their longest suffix under the source root.
+type Resolver struct {
+	root     string
+	resolved map[string]string
+}
+
+func NewResolver(root string) *Resolver {
+	return &Resolver{
+		root:     root,
+		resolved: make(map[string]string),
+	}
+}
+
+func (r *Resolver) Resolve(path string) (string, error) {
+	if resolved, ok := r.resolved[path]; ok {
+		return resolved, nil
+	}
+
+	if filepath.IsAbs(path) && isFile(path) {
+		r.resolved[path] = path
+		return path, nil
+	}
+
+	// Drop leading directories until the rest exists under the root
+	parts := strings.Split(filepath.ToSlash(filepath.Clean(path)), "/")
+	for i := range parts {
+		candidate := filepath.Join(r.root, filepath.Join(parts[i:]...))
+		if isFile(candidate) {
+			logger.GlobalLogger.Debugf("Resolved %s to %s", path, candidate)
+			r.resolved[path] = candidate
+			return candidate, nil
+		}
+	}
+
+	return "", fmt.Errorf("source file not found under %s: %s", r.root, path)
+}
+
+// Returns the file path of a "file:line" location
+func LocationPath(location string) string {
+	// Keep Windows drive letters (C:\...)
+	if len(location) >= 2 && location[1] == ':' {
+		if lastColon := strings.LastIndex(location, ":"); lastColon > 1 {
+			return location[:lastColon]
+		}
+		return location
+	}
+	return strings.Split(location, ":")[0]
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
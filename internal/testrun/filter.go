@@ -0,0 +1,105 @@
+package testrun
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Test runners the failing tests can be selected in
+const (
+	Jest   = "jest"
+	Vitest = "vitest"
+	Mocha  = "mocha"
+	Pytest = "pytest"
+	GoTest = "go"
+)
+
+var Runners = []string{Jest, Vitest, Mocha, Pytest, GoTest}
+
+// Detects the runner from the test command, e.g. "npx jest" or "go test ./...".
+// Returns an empty string when the command names no known runner.
+func Detect(command string) string {
+	fields := strings.Fields(command)
+	for i, field := range fields {
+		switch name := filepath.Base(field); name {
+		case Jest, Vitest, Mocha, Pytest:
+			return name
+		case "go":
+			if i+1 < len(fields) && fields[i+1] == "test" {
+				return GoTest
+			}
+		}
+	}
+	return ""
+}
+
+// Returns the arguments selecting only the given tests, named as in the report
+func FilterArgs(runner string, tests []string) ([]string, error) {
+	if len(tests) == 0 {
+		return nil, fmt.Errorf("no tests to select")
+	}
+
+	switch runner {
+	case Jest, Vitest:
+		return []string{"-t", namePattern(tests)}, nil
+	case Mocha:
+		return []string{"--grep", namePattern(tests)}, nil
+	case Pytest:
+		return []string{"-k", keywordExpression(tests)}, nil
+	case GoTest:
+		return []string{"-run", goTestPattern(tests)}, nil
+	default:
+		return nil, fmt.Errorf("unknown test runner '%s' (supported: %s)", runner, strings.Join(Runners, ", "))
+	}
+}
+
+// Matches the full names JavaScript runners filter on, where the describe
+// blocks and title are joined by spaces instead of the report's " > "
+func namePattern(tests []string) string {
+	alternatives := make([]string, len(tests))
+	for i, test := range tests {
+		parts := strings.Split(test, " > ")
+		for j := range parts {
+			parts[j] = regexp.QuoteMeta(parts[j])
+		}
+		alternatives[i] = strings.Join(parts, " ")
+	}
+	return "(?:" + strings.Join(alternatives, "|") + ")"
+}
+
+// Selects pytest tests by function name, as -k does not accept node IDs or
+// parameters
+func keywordExpression(tests []string) string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, test := range tests {
+		name := test
+		if index := strings.LastIndex(name, "::"); index >= 0 {
+			name = name[index+2:]
+		}
+		if bracket := strings.Index(name, "["); bracket > 0 {
+			name = name[:bracket]
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, " or ")
+}
+
+// Anchors the top-level test functions, which run their subtests
+func goTestPattern(tests []string) string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, test := range tests {
+		name, _, _ := strings.Cut(test, "/")
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, regexp.QuoteMeta(name))
+		}
+	}
+	return "^(?:" + strings.Join(names, "|") + ")$"
+}
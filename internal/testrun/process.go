@@ -0,0 +1,19 @@
+package testrun
+
+import (
+	"context"
+	"os/exec"
+	"time"
+)
+
+// Time the output pipes may stay open after the command is killed
+const waitDelay = 5 * time.Second
+
+// Returns a command that is killed with its child processes when the context
+// ends, so test runners spawning workers cannot outlive the timeout
+func Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	killProcessGroup(cmd)
+	cmd.WaitDelay = waitDelay
+	return cmd
+}
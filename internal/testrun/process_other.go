@@ -0,0 +1,9 @@
+//go:build !unix
+
+package testrun
+
+import "os/exec"
+
+// Only the command itself is killed, the wait delay closes the output pipes
+// child processes keep open
+func killProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package testrun
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// Starts the command in its own process group and kills the whole group
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
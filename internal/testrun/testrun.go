@@ -0,0 +1,131 @@
+package testrun
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Default limit for the setup and each test run
+const DefaultTimeout = 10 * time.Minute
+
+// Test command of the project, from the test section of the configuration
+type Config struct {
+	// Shell command running the test suite, e.g. "npx jest". The filter
+	// arguments are appended, so package scripts need "npm test --".
+	Command string `yaml:"command"`
+
+	// Runner the failing tests are selected in, detected from the command
+	// when empty
+	Runner string `yaml:"runner"`
+
+	// Shell command preparing a fresh checkout, e.g. "npm ci"
+	Setup string `yaml:"setup"`
+
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Outcome of a test run
+type Result struct {
+	Passed bool
+
+	// Combined stdout and stderr
+	Output string
+}
+
+// Returns the configured runner, or the one detected from the command
+func (c Config) RunnerName() (string, error) {
+	if c.Runner != "" {
+		if !slices.Contains(Runners, c.Runner) {
+			return "", fmt.Errorf("unknown test runner '%s' (supported: %s)", c.Runner, strings.Join(Runners, ", "))
+		}
+		return c.Runner, nil
+	}
+	if runner := Detect(c.Command); runner != "" {
+		return runner, nil
+	}
+	return "", fmt.Errorf("cannot detect the test runner of '%s', set test.runner (supported: %s)", c.Command, strings.Join(Runners, ", "))
+}
+
+// Returns the shell command running only the given tests
+func (c Config) FilteredCommand(tests []string) (string, error) {
+	runner, err := c.RunnerName()
+	if err != nil {
+		return "", err
+	}
+
+	args, err := FilterArgs(runner, tests)
+	if err != nil {
+		return "", err
+	}
+
+	command := c.Command
+	for _, arg := range args {
+		command += " " + shellQuote(arg)
+	}
+	return command, nil
+}
+
+// Runs the setup command in dir, if one is configured
+func (c Config) RunSetup(dir string) (string, error) {
+	if c.Setup == "" {
+		return "", nil
+	}
+	return c.execute(dir, c.Setup)
+}
+
+// Runs only the given tests in dir. Failing tests are a result, not an error;
+// a run exceeding the timeout counts as failing.
+func (c Config) Run(dir string, tests []string) (Result, error) {
+	command, err := c.FilteredCommand(tests)
+	if err != nil {
+		return Result{}, err
+	}
+
+	output, err := c.execute(dir, command)
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return Result{Passed: true, Output: output}, nil
+	case errors.Is(err, context.DeadlineExceeded):
+		return Result{Output: fmt.Sprintf("%s\n(timed out after %s)", output, c.timeout())}, nil
+	case errors.As(err, &exitErr):
+		return Result{Output: output}, nil
+	default:
+		return Result{}, err
+	}
+}
+
+func (c Config) execute(dir string, command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout())
+	defer cancel()
+
+	var output bytes.Buffer
+	cmd := Command(ctx, "sh", "-c", command)
+	cmd.Dir = dir
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return output.String(), ctx.Err()
+	}
+	return output.String(), err
+}
+
+func (c Config) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return DefaultTimeout
+}
+
+func shellQuote(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
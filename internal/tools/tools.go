@@ -0,0 +1,337 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/anthonydip/sherlock/internal/ai/message"
+	"github.com/anthonydip/sherlock/internal/git"
+)
+
+// Limits keeping tool results within the AI's context
+const (
+	maxReadLines   = 200
+	maxGrepMatches = 50
+	maxHistory     = 10
+	maxResult      = 8000
+)
+
+// Tools the AI can call during an analysis, confined to one repository
+type Toolbox struct {
+	repo *git.Repository
+	root string
+}
+
+func New(repo *git.Repository) *Toolbox {
+	return &Toolbox{repo: repo, root: repo.Path()}
+}
+
+type readFileArgs struct {
+	Path  string `json:"path"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+type blameArgs struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+}
+
+type fileHistoryArgs struct {
+	Path string `json:"path"`
+}
+
+type grepArgs struct {
+	Pattern     string `json:"pattern"`
+	PathPattern string `json:"path_pattern"`
+}
+
+func (t *Toolbox) Specs() []message.ToolSpec {
+	return []message.ToolSpec{
+		spec("read_file", "Read lines of a file in the repository. Returns at most 200 lines per call.", map[string]any{
+			"path":  stringParam("Path relative to the repository root"),
+			"start": intParam("First line to read, starting at 1"),
+			"end":   intParam("Last line to read"),
+		}, "path"),
+		spec("blame", "Show the commit that last changed a line of a file, with its diff.", map[string]any{
+			"path": stringParam("Path relative to the repository root"),
+			"line": intParam("Line number, starting at 1"),
+		}, "path", "line"),
+		spec("file_history", "List the most recent commits that changed a file, following renames.", map[string]any{
+			"path": stringParam("Path relative to the repository root"),
+		}, "path"),
+		spec("grep", "Search the committed files for lines matching a regular expression (RE2 syntax).", map[string]any{
+			"pattern":      stringParam("Regular expression matched against each line"),
+			"path_pattern": stringParam("Optional regular expression the file path must match"),
+		}, "pattern"),
+	}
+}
+
+func (t *Toolbox) Call(name string, arguments string) (string, error) {
+	var result string
+	var err error
+
+	switch name {
+	case "read_file":
+		var args readFileArgs
+		if err = decode(arguments, &args); err == nil {
+			result, err = t.readFile(args)
+		}
+	case "blame":
+		var args blameArgs
+		if err = decode(arguments, &args); err == nil {
+			result, err = t.blame(args)
+		}
+	case "file_history":
+		var args fileHistoryArgs
+		if err = decode(arguments, &args); err == nil {
+			result, err = t.fileHistory(args)
+		}
+	case "grep":
+		var args grepArgs
+		if err = decode(arguments, &args); err == nil {
+			result, err = t.grep(args)
+		}
+	default:
+		return "", fmt.Errorf("unknown tool '%s'", name)
+	}
+
+	if err != nil {
+		return "", err
+	}
+	if len(result) > maxResult {
+		result = result[:maxResult] + "\n... (output truncated)"
+	}
+	return result, nil
+}
+
+func (t *Toolbox) readFile(args readFileArgs) (string, error) {
+	path, err := t.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := os.ReadFile(filepath.Join(t.root, path))
+	if err != nil {
+		return "", fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	if bytes.IndexByte(content, 0) >= 0 {
+		return "", fmt.Errorf("%s is a binary file", path)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+
+	start := max(args.Start, 1)
+	end := args.End
+	if end < start {
+		end = start + maxReadLines - 1
+	}
+	end = min(end, start+maxReadLines-1, len(lines))
+	if start > len(lines) {
+		return "", fmt.Errorf("%s has only %d lines", path, len(lines))
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s (lines %d-%d of %d)\n", path, start, end, len(lines)))
+	for i := start; i <= end; i++ {
+		sb.WriteString(fmt.Sprintf("%5d: %s\n", i, lines[i-1]))
+	}
+	return sb.String(), nil
+}
+
+func (t *Toolbox) blame(args blameArgs) (string, error) {
+	path, err := t.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	repo, repoPath, err := t.repo.RepositoryFor(path)
+	if err != nil {
+		return "", err
+	}
+
+	blame, err := repo.GetBlame(repoPath)
+	if err != nil {
+		return "", fmt.Errorf("cannot blame %s: %w", path, err)
+	}
+	if args.Line < 1 || args.Line > len(blame.Lines) {
+		return "", fmt.Errorf("%s has %d lines, cannot blame line %d", path, len(blame.Lines), args.Line)
+	}
+
+	line := blame.Lines[args.Line-1]
+	var sb strings.Builder
+	if line.Uncommitted {
+		sb.WriteString(fmt.Sprintf("%s:%d is an uncommitted change\n", path, args.Line))
+	} else {
+		author := line.AuthorName
+		if author == "" {
+			author = line.Author
+		}
+		sb.WriteString(fmt.Sprintf("%s:%d last changed in %s by %s on %s\n",
+			path, args.Line, line.Hash.String()[:7], author, line.Date.Format("2006-01-02")))
+	}
+	sb.WriteString(fmt.Sprintf("Line: %s\n", line.Text))
+
+	if changes, err := repo.GetLineChanges(repoPath, args.Line); err == nil && changes != "" {
+		sb.WriteString(fmt.Sprintf("\nChange:\n%s\n", changes))
+	}
+	return sb.String(), nil
+}
+
+func (t *Toolbox) fileHistory(args fileHistoryArgs) (string, error) {
+	path, err := t.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	repo, repoPath, err := t.repo.RepositoryFor(path)
+	if err != nil {
+		return "", err
+	}
+
+	commits, err := repo.GetEnhancedFileHistory(repoPath, maxHistory)
+	if err != nil {
+		return "", fmt.Errorf("cannot read history of %s: %w", path, err)
+	}
+	if len(commits) == 0 {
+		return fmt.Sprintf("No commits found for %s", path), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Most recent commits changing %s:\n", path))
+	for _, commit := range commits {
+		hash := commit.Hash[:min(7, len(commit.Hash))]
+		if commit.Uncommitted {
+			hash = "working tree"
+		}
+		sb.WriteString(fmt.Sprintf("- %s by %s on %s: %s", hash, commit.Author, commit.Date.Format("2006-01-02"), strings.Split(commit.Message, "\n")[0]))
+		if commit.PreviousPath != "" {
+			sb.WriteString(fmt.Sprintf(" [renamed from %s]", commit.PreviousPath))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String(), nil
+}
+
+func (t *Toolbox) grep(args grepArgs) (string, error) {
+	pattern, err := regexp.Compile(args.Pattern)
+	if err != nil || args.Pattern == "" {
+		return "", fmt.Errorf("invalid pattern '%s'", args.Pattern)
+	}
+
+	var pathPattern *regexp.Regexp
+	if args.PathPattern != "" {
+		if pathPattern, err = regexp.Compile(args.PathPattern); err != nil {
+			return "", fmt.Errorf("invalid path pattern '%s'", args.PathPattern)
+		}
+	}
+
+	matches, more, err := t.repo.Grep(pattern, pathPattern, maxGrepMatches)
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return fmt.Sprintf("No matches for %s", args.Pattern), nil
+	}
+
+	var sb strings.Builder
+	for _, match := range matches {
+		sb.WriteString(fmt.Sprintf("%s:%d: %s\n", match.Path, match.Line, strings.TrimSpace(match.Text)))
+	}
+	if more {
+		sb.WriteString(fmt.Sprintf("... (more than %d matches, narrow the pattern)\n", maxGrepMatches))
+	}
+	return sb.String(), nil
+}
+
+// Resolves a path requested by the AI to a path relative to the repository
+// root, rejecting anything outside of it
+func (t *Toolbox) resolve(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("missing path")
+	}
+
+	full := filepath.FromSlash(path)
+	if !filepath.IsAbs(full) {
+		full = filepath.Join(t.root, full)
+	}
+	full = filepath.Clean(full)
+
+	rel, err := filepath.Rel(t.root, full)
+	if err != nil || !inside(rel) {
+		return "", fmt.Errorf("%s is outside the repository", path)
+	}
+
+	// Symlinks must not lead out of the repository either
+	if resolved, err := filepath.EvalSymlinks(full); err == nil {
+		root, err := filepath.EvalSymlinks(t.root)
+		if err != nil {
+			return "", err
+		}
+		target, err := filepath.Rel(root, resolved)
+		if err != nil || !inside(target) {
+			return "", fmt.Errorf("%s is outside the repository", path)
+		}
+		if inGitDir(target) {
+			return "", fmt.Errorf("%s is not a source file", path)
+		}
+	}
+
+	if inGitDir(rel) {
+		return "", fmt.Errorf("%s is not a source file", path)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// Reports whether a path lies in a .git directory, including those of nested
+// repositories and submodules, whose config may hold credentials
+func inGitDir(rel string) bool {
+	for _, segment := range strings.Split(filepath.ToSlash(rel), "/") {
+		if strings.EqualFold(segment, ".git") {
+			return true
+		}
+	}
+	return false
+}
+
+func inside(rel string) bool {
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func decode(arguments string, args any) error {
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	if err := json.Unmarshal([]byte(arguments), args); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+func spec(name, description string, properties map[string]any, required ...string) message.ToolSpec {
+	return message.ToolSpec{
+		Type: "function",
+		Function: message.FunctionSpec{
+			Name:        name,
+			Description: description,
+			Parameters: map[string]any{
+				"type":       "object",
+				"properties": properties,
+				"required":   required,
+			},
+		},
+	}
+}
+
+func stringParam(description string) map[string]any {
+	return map[string]any{"type": "string", "description": description}
+}
+
+func intParam(description string) map[string]any {
+	return map[string]any{"type": "integer", "description": description}
+}